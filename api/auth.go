@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/veteran-software/picarto-api-wrapper/oauth"
+)
+
+type userAuthKey struct{}
+
+// userAuth is the per-call authentication stored in a context; at most one of its fields is set
+type userAuth struct {
+	token  *oauth.Token
+	source oauth.TokenSource
+}
+
+// ContextWithUserToken returns a copy of ctx that makes requests authenticate with token in place of the client's
+// own credentials, e.g. so a user token with permission readpub gets that user's followed status. The token is sent
+// as is; it is neither refreshed nor replaced when Picarto refuses it.
+//
+//goland:noinspection GoUnusedExportedFunction
+func ContextWithUserToken(ctx context.Context, token *oauth.Token) context.Context {
+	return context.WithValue(ctx, userAuthKey{}, userAuth{token: token})
+}
+
+// ContextWithTokenSource returns a copy of ctx that makes requests authenticate with the tokens of source in place of
+// the client's own credentials. Unlike ContextWithUserToken, a refused token is replaced if source can replace it.
+//
+//goland:noinspection GoUnusedExportedFunction
+func ContextWithTokenSource(ctx context.Context, source oauth.TokenSource) context.Context {
+	return context.WithValue(ctx, userAuthKey{}, userAuth{source: source})
+}
+
+// userAuthFrom returns the per-call authentication stored in ctx, and whether there is any
+func userAuthFrom(ctx context.Context) (userAuth, bool) {
+	auth, ok := ctx.Value(userAuthKey{}).(userAuth)
+
+	return auth, ok && (auth.token != nil || auth.source != nil)
+}
+
+// tokenSourceFor returns the token source of a request made with ctx; nil when it is sent with the client secret
+func (c *Client) tokenSourceFor(ctx context.Context) oauth.TokenSource {
+	auth, ok := userAuthFrom(ctx)
+	switch {
+	case !ok:
+		return c.tokenSource
+	case auth.token != nil:
+		return oauth.StaticTokenSource(auth.token)
+	}
+
+	return auth.source
+}
+
+// accessToken returns the token to send with the next request; nil when the request has no token source
+func (c *Client) accessToken(ctx context.Context) (*oauth.Token, error) {
+	source := c.tokenSourceFor(ctx)
+	if source == nil {
+		return nil, nil
+	}
+
+	token, err := source.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("picarto: unable to get an access token: %w", err)
+	}
+
+	return token, nil
+}
+
+// bearer returns the Bearer credential for a request sent with token, falling back to the static client secret
+func (c *Client) bearer(token *oauth.Token) string {
+	if token != nil {
+		return token.AccessToken
+	}
+	if c.clientSecret != nil {
+		return *c.clientSecret
+	}
+
+	return ""
+}
+
+// rejectToken asks the token source to replace token after Picarto refused it, and reports whether it did
+func (c *Client) rejectToken(ctx context.Context, token *oauth.Token) bool {
+	rejecter, ok := c.tokenSourceFor(ctx).(oauth.TokenRejecter)
+	if !ok {
+		return false
+	}
+
+	replacement, err := rejecter.Reject(ctx, token)
+	if err != nil {
+		c.logger.Warn("picarto: unable to replace a refused access token", logKeyError, err)
+		return false
+	}
+
+	return replacement != nil && replacement.AccessToken != token.AccessToken
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/veteran-software/picarto-api-wrapper/oauth"
+)
+
+// rotatingTokenSource hands out "token-N" and moves on to the next N whenever the current token is rejected
+type rotatingTokenSource struct {
+	sync.Mutex
+
+	n        int
+	rejected int
+}
+
+func (s *rotatingTokenSource) current() *oauth.Token {
+	return &oauth.Token{AccessToken: "token-" + strconv.Itoa(s.n)}
+}
+
+func (s *rotatingTokenSource) Token(context.Context) (*oauth.Token, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.current(), nil
+}
+
+func (s *rotatingTokenSource) Reject(_ context.Context, token *oauth.Token) (*oauth.Token, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.rejected++
+	if token.AccessToken == s.current().AccessToken {
+		s.n++
+	}
+
+	return s.current(), nil
+}
+
+func TestTokenSourceReplacesRefusedToken(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		w.Header().Set("x-ratelimit-remaining", "100")
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeFixture(w, categoriesFixture)
+	}))
+	defer srv.Close()
+
+	source := &rotatingTokenSource{}
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClientSecret("ignored"), WithTokenSource(source))
+
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if len(seen) != 2 || seen[0] != "Bearer token-0" || seen[1] != "Bearer token-1" {
+		t.Errorf("refused token was not replaced; sent: %v", seen)
+	}
+	mu.Unlock()
+
+	// A replacement that is refused as well is not retried again
+	source.Lock()
+	source.n = 5
+	source.Unlock()
+
+	if _, err := c.GetCategories(); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrUnauthorized, err)
+	}
+	if source.rejected != 2 {
+		t.Errorf("unexpected rejection count; expected: 2, got: %d", source.rejected)
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	config := oauth.NewConfig("CLIENT_ID", "http://localhost/callback")
+	source := config.TokenSource(&oauth.Token{AccessToken: "expired", Expiry: clockStart}, nil)
+	c := NewClient("TEST_TOKEN", WithTokenSource(source))
+
+	if _, err := c.GetCategories(); !errors.Is(err, oauth.ErrNoRefreshToken) {
+		t.Errorf("unexpected error; expected: %v, got: %v", oauth.ErrNoRefreshToken, err)
+	}
+}
+
+func TestContextWithUserToken(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Authorization"))
+		mu.Unlock()
+
+		w.Header().Set("x-ratelimit-remaining", "100")
+		writeFixture(w, channelFixture)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClientSecret("SECRET"), WithCache(NewMemoryCache(0)))
+
+	alice := ContextWithUserToken(context.Background(), &oauth.Token{AccessToken: "alice",
+		Scopes: []oauth.Scope{oauth.ScopeReadPub}})
+	bob := ContextWithUserToken(context.Background(), &oauth.Token{AccessToken: "bob",
+		Scopes: []oauth.Scope{oauth.ScopeReadPub}})
+
+	// Each user gets their own response, and a repeated call is served from that user's cache entry
+	for _, ctx := range []context.Context{alice, bob, context.Background(), alice, bob} {
+		if _, err := c.GetChannelByIDContext(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"Bearer alice", "Bearer bob", "Bearer SECRET"}
+	if len(seen) != len(expected) {
+		t.Fatalf("unexpected requests; expected: %v, got: %v", expected, seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("unexpected Authorization header; expected: %q, got: %q", expected[i], seen[i])
+		}
+	}
+}
+
+func TestContextWithTokenSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-remaining", "100")
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeFixture(w, categoriesFixture)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClientSecret("SECRET"))
+
+	// A per-call source has its refused token replaced like the client's own
+	source := &rotatingTokenSource{}
+	if _, err := c.GetCategoriesContext(ContextWithTokenSource(context.Background(), source)); err != nil {
+		t.Fatal(err)
+	}
+	if source.rejected != 1 {
+		t.Errorf("unexpected rejection count; expected: 1, got: %d", source.rejected)
+	}
+
+	// A single token is not
+	ctx := ContextWithUserToken(context.Background(), &oauth.Token{AccessToken: "token-0"})
+	if _, err := c.GetCategoriesContext(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrUnauthorized, err)
+	}
+}
@@ -0,0 +1,384 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheFamily
+//
+// A group of endpoints sharing a cache TTL, named after the path prefix they have in common
+type CacheFamily string
+
+const (
+	CacheCategories CacheFamily = "/categories"
+	CacheChannel    CacheFamily = "/channel/"
+	CacheOnline     CacheFamily = "/online"
+	CacheSearch     CacheFamily = "/search/"
+)
+
+// cacheFamilies lists every family that may be cached
+var cacheFamilies = []CacheFamily{CacheCategories, CacheChannel, CacheOnline, CacheSearch}
+
+// DefaultCacheTTLs is how long responses of each family are served from the cache once caching is enabled
+var DefaultCacheTTLs = map[CacheFamily]time.Duration{
+	CacheCategories: 1 * time.Hour,
+	CacheChannel:    1 * time.Minute,
+	CacheOnline:     30 * time.Second,
+	CacheSearch:     1 * time.Minute,
+}
+
+// defaultCacheSize is how many responses the default MemoryCache holds
+const defaultCacheSize = 256
+
+// CacheEntry
+//
+// A successful response kept by a Cache
+type CacheEntry struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	// Stored is when the response was received
+	Stored time.Time `json:"stored"`
+	// Expires is when the response stops being fresh
+	Expires time.Time `json:"expires"`
+}
+
+// Fresh reports whether the entry may still be served at now
+func (e CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// Cache
+//
+// Holds successful GET responses. Entries past their expiry are still returned by Get; the client decides whether
+// they are fresh. A Cache must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry)
+	Delete(key string)
+}
+
+// CacheMode
+//
+// Decides how a single call uses the cache. Set it per call with ContextWithCacheMode; calls without one use
+// CacheDefault.
+type CacheMode int
+
+const (
+	// CacheDefault serves fresh responses from the cache and stores new ones
+	CacheDefault CacheMode = iota
+	// CacheBypass neither reads nor writes the cache
+	CacheBypass
+	// CacheRefresh skips the cached response but stores the new one, replacing whatever was cached
+	CacheRefresh
+)
+
+type cacheModeKey struct{}
+
+// ContextWithCacheMode returns a copy of ctx that makes requests use the cache according to mode
+//
+//goland:noinspection GoUnusedExportedFunction
+func ContextWithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+// cacheModeFrom returns the cache mode stored in ctx
+func cacheModeFrom(ctx context.Context) CacheMode {
+	mode, ok := ctx.Value(cacheModeKey{}).(CacheMode)
+	if !ok {
+		return CacheDefault
+	}
+
+	return mode
+}
+
+// MemoryCache
+//
+// The default Cache. It keeps up to a fixed number of responses in memory and evicts the least recently used one when
+// full.
+type MemoryCache struct {
+	sync.Mutex
+
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// NewMemoryCache creates an empty cache holding up to size responses; a size of 0 or less uses a default of 256
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+
+	return &MemoryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(key string) (CacheEntry, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	m.order.MoveToFront(el)
+
+	return el.Value.(*memoryCacheItem).entry, true
+}
+
+func (m *MemoryCache) Set(key string, entry CacheEntry) {
+	m.Lock()
+	defer m.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoryCacheItem).entry = entry
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryCacheItem{key: key, entry: entry})
+
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	if el, ok := m.entries[key]; ok {
+		m.order.Remove(el)
+		delete(m.entries, key)
+	}
+}
+
+// Len returns the number of cached responses
+func (m *MemoryCache) Len() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.order.Len()
+}
+
+// InvalidateCache drops the cached response for path, e.g. "/channel/name/AgueMort", so the next call fetches it again
+func (c *Client) InvalidateCache(path string) {
+	c.InvalidateCacheContext(context.Background(), path)
+}
+
+// InvalidateCacheContext drops the cached response for path fetched with the per-call credentials of ctx, e.g. the
+// user token set with ContextWithUserToken
+func (c *Client) InvalidateCacheContext(ctx context.Context, path string) {
+	if c.cache == nil {
+		return
+	}
+
+	key, err := c.cacheKey(ctx, http.MethodGet, c.baseURL+path)
+	if err != nil {
+		c.logger.Warn("picarto: unable to invalidate cached response", logKeyEndpoint, path, logKeyError, err)
+		return
+	}
+
+	c.cache.Delete(key)
+}
+
+// cacheKey identifies a response by method, URL and the credentials it was fetched with, since some responses
+// depend on who asked
+func (c *Client) cacheKey(ctx context.Context, method, route string) (string, error) {
+	credentials, err := c.credentialKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return method + " " + route + " " + credentials, nil
+}
+
+// credentialKey is a digest of the credentials a request made with ctx is sent with that is safe to keep in a cache
+// key. It has to stay the same across restarts for a FileCache to stay warm, so a token is identified by the cache
+// namespace of the client, when there is one, or else by the access token itself.
+func (c *Client) credentialKey(ctx context.Context) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.clientID))
+
+	_, perCall := userAuthFrom(ctx)
+	switch {
+	case !perCall && c.tokenSource != nil && c.cacheNamespace != "":
+		h.Write([]byte("\x00namespace\x00"))
+		h.Write([]byte(c.cacheNamespace))
+	case perCall || c.tokenSource != nil:
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte("\x00token\x00"))
+		if token != nil {
+			h.Write([]byte(token.AccessToken))
+		}
+	case c.clientSecret != nil:
+		h.Write([]byte{0})
+		h.Write([]byte(*c.clientSecret))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:8]), nil
+}
+
+// cacheFamilyOf returns the family route belongs to, if it belongs to any
+func (c *Client) cacheFamilyOf(route string) (CacheFamily, bool) {
+	if !strings.HasPrefix(route, c.baseURL) {
+		return "", false
+	}
+	path := strings.TrimPrefix(route, c.baseURL)
+
+	for _, family := range cacheFamilies {
+		if strings.HasPrefix(path, string(family)) {
+			return family, true
+		}
+	}
+
+	return "", false
+}
+
+// cachedRequest serves GET requests from the cache under key, as made by cacheKey, when it can and stores the
+// successful responses it could not serve. When the request fails and stale-if-error allows it, the last good response
+// is returned with a *StaleError. Other requests ignore key.
+func (c *Client) cachedRequest(ctx context.Context, key, method, route, contentType string, b *interface{}) (
+	*http.Response, error) {
+	mode := cacheModeFrom(ctx)
+	family, ok := c.cacheFamilyOf(route)
+	ttl, maxStale := c.cacheTTL[family], c.maxStale[family]
+	if c.cache == nil || method != http.MethodGet || b != nil || mode == CacheBypass || !ok ||
+		(ttl <= 0 && maxStale <= 0) {
+		return c.request(ctx, method, route, contentType, b)
+	}
+
+	endpoint := strings.TrimPrefix(route, c.baseURL)
+	entry, cached := c.cache.Get(key)
+	if cached && mode == CacheDefault && entry.Fresh(c.clock.Now()) {
+		c.logger.Debug("picarto: served from cache", logKeyEndpoint, endpoint)
+		return entry.response(), nil
+	}
+
+	resp, err := c.request(ctx, method, route, contentType, b)
+	if err != nil {
+		if stale, staleErr := c.staleResponse(ctx, key, route, err); staleErr != nil {
+			return stale, staleErr
+		}
+
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.clock.Now()
+	c.cache.Set(key, CacheEntry{
+		Header:  resp.Header.Clone(),
+		Body:    body,
+		Stored:  now,
+		Expires: now.Add(ttl),
+	})
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return resp, nil
+}
+
+// staleResponse returns the last good response for the GET request of route cached under key, along with a
+// *StaleError wrapping err, when stale-if-error allows serving it for err; otherwise it returns nil and a nil
+// *StaleError. The key is made up front, since ctx may already be done by the time a stale response is wanted.
+func (c *Client) staleResponse(ctx context.Context, key, route string, err error) (*http.Response, *StaleError) {
+	family, ok := c.cacheFamilyOf(route)
+	maxStale := c.maxStale[family]
+	if c.cache == nil || !ok || maxStale <= 0 || cacheModeFrom(ctx) == CacheBypass || !staleIfError(err) {
+		return nil, nil
+	}
+
+	entry, cached := c.cache.Get(key)
+	now := c.clock.Now()
+	if !cached || now.Sub(entry.Expires) > maxStale {
+		return nil, nil
+	}
+
+	age := now.Sub(entry.Stored)
+	c.logger.Warn("picarto: serving stale response", logKeyEndpoint, strings.TrimPrefix(route, c.baseURL), logKeyAge,
+		age, logKeyError, err)
+
+	return entry.response(), &StaleError{Age: age, Err: err}
+}
+
+// staleIfError reports whether err is an outage that a stale response may cover for: a bad gateway, an unavailable
+// service, a timeout, including the caller's own deadline, or an open circuit breaker
+func staleIfError(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// response rebuilds the cached response; the caller may consume and modify it freely
+func (e CacheEntry) response() *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileCache
+//
+// A Cache kept as one JSON file per response in a directory, so a restarted process starts warm. Entries keep the
+// time they were stored and when they expire; expired entries are fetched again the next time they are asked for.
+// Files are replaced atomically, so several processes may share the directory.
+type FileCache struct {
+	sync.Mutex
+
+	dir string
+}
+
+// fileCacheEntry is the on-disk form of a CacheEntry; the key is kept so that files can be told apart when inspected
+type fileCacheEntry struct {
+	Key string `json:"key"`
+	CacheEntry
+}
+
+// fileCacheExt is the extension of every entry file in the cache directory
+const fileCacheExt = ".json"
+
+// NewFileCache opens, or creates, the cache directory at dir
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileCache{dir: dir}, nil
+}
+
+// path returns the file holding key; keys are hashed since they contain URLs
+func (f *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+fileCacheExt)
+}
+
+// Get returns the entry stored for key. A missing or unreadable file is a miss.
+func (f *FileCache) Get(key string) (CacheEntry, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	entry, err := readFileCacheEntry(f.path(key))
+	if err != nil || entry.Key != key {
+		return CacheEntry{}, false
+	}
+
+	return entry.CacheEntry, true
+}
+
+// Set stores entry for key. Failures to write are ignored, as the response can always be fetched again.
+func (f *FileCache) Set(key string, entry CacheEntry) {
+	f.Lock()
+	defer f.Unlock()
+
+	data, err := json.Marshal(fileCacheEntry{Key: key, CacheEntry: entry})
+	if err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(f.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+func (f *FileCache) Delete(key string) {
+	f.Lock()
+	defer f.Unlock()
+
+	_ = os.Remove(f.path(key))
+}
+
+// Prune removes every entry that expired before t, e.g. time.Now().Add(-24*time.Hour) to keep a day of stale data
+func (f *FileCache) Prune(t time.Time) error {
+	f.Lock()
+	defer f.Unlock()
+
+	files, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileCacheExt) {
+			continue
+		}
+
+		path := filepath.Join(f.dir, file.Name())
+		entry, err := readFileCacheEntry(path)
+		if err == nil && !entry.Expires.Before(t) {
+			continue
+		}
+
+		// Unreadable files are removed along with expired ones
+		if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func readFileCacheEntry(path string) (fileCacheEntry, error) {
+	var entry fileCacheEntry
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(data, &entry)
+
+	return entry, err
+}
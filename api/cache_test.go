@@ -0,0 +1,414 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/veteran-software/picarto-api-wrapper/oauth"
+)
+
+// newCountingServer serves the fixtures and counts the requests that reach it
+func newCountingServer(t *testing.T) (*httptest.Server, *int32) {
+	var calls int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/categories", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeFixture(w, categoriesFixture)
+	})
+	mux.HandleFunc("/channel/name/AgueMort", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeFixture(w, channelFixture)
+	})
+	mux.HandleFunc("/notifications", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeFixture(w, `{}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func TestCacheServesUntilExpiry(t *testing.T) {
+	srv, calls := newCountingServer(t)
+	clock := NewFakeClock(clockStart)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClock(clock), WithCache(NewMemoryCache(0)),
+		WithCacheTTL(CacheChannel, 10*time.Second))
+
+	for i := 0; i < 3; i++ {
+		channel, err := c.GetChannelByName("AgueMort")
+		if err != nil {
+			t.Fatal(err)
+		}
+		evalChannelFields(t, channel)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("unexpected request count; expected: 1, got: %d", n)
+	}
+
+	clock.Advance(10 * time.Second)
+
+	if _, err := c.GetChannelByName("AgueMort"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expired response was served; request count: %d", n)
+	}
+}
+
+func TestCacheModes(t *testing.T) {
+	srv, calls := newCountingServer(t)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithCache(NewMemoryCache(0)))
+
+	expect := func(want int32) {
+		t.Helper()
+		if n := atomic.LoadInt32(calls); n != want {
+			t.Errorf("unexpected request count; expected: %d, got: %d", want, n)
+		}
+	}
+
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+	expect(1)
+
+	// Bypassing neither reads nor replaces the cached response
+	if _, err := c.GetCategoriesContext(ContextWithCacheMode(context.Background(), CacheBypass)); err != nil {
+		t.Fatal(err)
+	}
+	expect(2)
+
+	// Refreshing fetches again, and the new response is served afterwards
+	if _, err := c.GetCategoriesContext(ContextWithCacheMode(context.Background(), CacheRefresh)); err != nil {
+		t.Fatal(err)
+	}
+	expect(3)
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+	expect(3)
+
+	c.InvalidateCache("/categories")
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+	expect(4)
+
+	// Endpoints outside the cached families always reach the server
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetNotifications(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect(6)
+}
+
+func TestCacheDisabledByDefault(t *testing.T) {
+	srv, calls := newCountingServer(t)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetCategories(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("unexpected request count; expected: 2, got: %d", n)
+	}
+}
+
+func TestCacheKeyIncludesCredentials(t *testing.T) {
+	a := NewClient("TEST_TOKEN", WithClientSecret("one"))
+	b := NewClient("TEST_TOKEN", WithClientSecret("two"))
+
+	if cacheKeyOf(t, a, context.Background()) == cacheKeyOf(t, b, context.Background()) {
+		t.Error("clients with different secrets share a cache key")
+	}
+}
+
+func TestCacheKeyStableAcrossRestarts(t *testing.T) {
+	token := &oauth.Token{AccessToken: "access"}
+
+	// Two processes loading the same stored token use the same key
+	before := NewClient("TEST_TOKEN", WithTokenSource(oauth.StaticTokenSource(token)))
+	after := NewClient("TEST_TOKEN", WithTokenSource(oauth.StaticTokenSource(token)))
+	if cacheKeyOf(t, before, context.Background()) != cacheKeyOf(t, after, context.Background()) {
+		t.Error("the cache key of a token source changed with the process")
+	}
+
+	ctx := ContextWithTokenSource(context.Background(), oauth.StaticTokenSource(token))
+	if cacheKeyOf(t, before, ctx) != cacheKeyOf(t, after, ContextWithTokenSource(context.Background(),
+		oauth.StaticTokenSource(token))) {
+		t.Error("the cache key of a per-call token source changed with the process")
+	}
+
+	// A namespace keeps the key across token refreshes
+	source := &rotatingTokenSource{}
+	c := NewClient("TEST_TOKEN", WithTokenSource(source), WithCacheNamespace("user-1"))
+	key := cacheKeyOf(t, c, context.Background())
+	if _, err := source.Reject(context.Background(), source.current()); err != nil {
+		t.Fatal(err)
+	}
+	if cacheKeyOf(t, c, context.Background()) != key {
+		t.Error("the cache key of a namespaced client changed with its token")
+	}
+	if other := NewClient("TEST_TOKEN", WithTokenSource(source), WithCacheNamespace("user-2")); cacheKeyOf(t, other,
+		context.Background()) == key {
+		t.Error("clients with different namespaces share a cache key")
+	}
+}
+
+// cacheKeyOf returns the cache key c uses for the categories with ctx
+func cacheKeyOf(t *testing.T, c *Client, ctx context.Context) string {
+	t.Helper()
+
+	key, err := c.cacheKey(ctx, http.MethodGet, api+"/categories")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	m := NewMemoryCache(2)
+	m.Set("a", CacheEntry{Body: []byte("a")})
+	m.Set("b", CacheEntry{Body: []byte("b")})
+
+	// Reading a makes b the least recently used entry
+	if _, ok := m.Get("a"); !ok {
+		t.Fatal("entry a is missing")
+	}
+	m.Set("c", CacheEntry{Body: []byte("c")})
+
+	if _, ok := m.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if _, ok := m.Get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+	if m.Len() != 2 {
+		t.Errorf("unexpected size; expected: 2, got: %d", m.Len())
+	}
+}
+
+func TestFileCacheSurvivesRestart(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	srv, calls := newCountingServer(t)
+	clock := NewFakeClock(clockStart)
+
+	newCachedClient := func() *Client {
+		t.Helper()
+		cache, err := NewFileCache(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClock(clock), WithCache(cache))
+	}
+
+	if _, err := newCachedClient().GetChannelByName("AgueMort"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new client on the same directory stands in for a restarted process
+	restarted := newCachedClient()
+	channel, err := restarted.GetChannelByName("AgueMort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evalChannelFields(t, channel)
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("restarted client did not use the cache; request count: %d", n)
+	}
+
+	// Once expired the entry is fetched again and replaced
+	clock.Advance(DefaultCacheTTLs[CacheChannel])
+	if _, err = restarted.GetChannelByName("AgueMort"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expired entry was not revalidated; request count: %d", n)
+	}
+	if _, err = restarted.GetChannelByName("AgueMort"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("revalidated entry was not stored; request count: %d", n)
+	}
+}
+
+func TestFileCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Set("old", CacheEntry{Stored: clockStart, Expires: clockStart.Add(1 * time.Minute)})
+	cache.Set("new", CacheEntry{Stored: clockStart, Expires: clockStart.Add(1 * time.Hour)})
+
+	if err = cache.Prune(clockStart.Add(30 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.Get("old"); ok {
+		t.Error("expired entry was not pruned")
+	}
+	if entry, ok := cache.Get("new"); !ok || !entry.Expires.Equal(clockStart.Add(1*time.Hour)) {
+		t.Errorf("fresh entry was lost; got: %+v", entry)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("unexpected files left in the cache directory: %d", len(files))
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	var status int32 = http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A status of 0 stands for a server that does not answer in time
+		if atomic.LoadInt32(&status) == 0 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(1 * time.Second):
+			}
+			return
+		}
+		if s := int(atomic.LoadInt32(&status)); s != http.StatusOK {
+			w.WriteHeader(s)
+			return
+		}
+		writeFixture(w, channelFixture)
+	}))
+	defer srv.Close()
+
+	clock := NewFakeClock(clockStart)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClock(clock), WithRetryPolicy(RetryPolicy{}),
+		WithTimeout(100*time.Millisecond), WithStaleIfError(CacheChannel, 10*time.Minute))
+
+	if _, err := c.GetChannelByName("AgueMort"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(5 * time.Minute)
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+
+	channel, err := c.GetChannelByName("AgueMort")
+	var stale *StaleError
+	if !errors.As(err, &stale) || !errors.Is(err, ErrStale) || !errors.Is(err, ErrServerError) {
+		t.Fatalf("expected a stale response; got: %v", err)
+	}
+	if stale.Age != 5*time.Minute {
+		t.Errorf("unexpected age; expected: 5m, got: %s", stale.Age)
+	}
+	if channel == nil {
+		t.Fatal("stale channel was not returned")
+	}
+	evalChannelFields(t, channel)
+
+	// So do timeouts, whether the client's own or the caller's deadline
+	atomic.StoreInt32(&status, 0)
+
+	var netErr net.Error
+	if channel, err = c.GetChannelByName("AgueMort"); channel == nil || !errors.Is(err, ErrStale) ||
+		!errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a stale response for a client timeout; got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if channel, err = c.GetChannelByNameContext(ctx, "AgueMort"); channel == nil || !errors.Is(err, ErrStale) ||
+		!errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a stale response for a caller deadline; got: %v", err)
+	}
+
+	// Errors other than outages are passed on as they are
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	if channel, err = c.GetChannelByName("AgueMort"); channel != nil || errors.Is(err, ErrStale) {
+		t.Errorf("stale response served for a 500; got: %v", err)
+	}
+
+	// Past the maximum staleness the failure is returned
+	atomic.StoreInt32(&status, http.StatusBadGateway)
+	clock.Advance(10 * time.Minute)
+	if channel, err = c.GetChannelByName("AgueMort"); channel != nil || errors.Is(err, ErrStale) {
+		t.Errorf("response older than the maximum staleness was served; got: %v", err)
+	}
+}
+
+// deadlineTokenSource refuses to hand out its token once ctx is done, as a source that has to refresh would
+type deadlineTokenSource struct{}
+
+func (deadlineTokenSource) Token(ctx context.Context) (*oauth.Token, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &oauth.Token{AccessToken: "TEST_ACCESS_TOKEN"}, nil
+}
+
+func TestStaleIfErrorAfterDeadlineWithTokenSource(t *testing.T) {
+	var hang int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&hang) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(1 * time.Second):
+			}
+			return
+		}
+		writeFixture(w, channelFixture)
+	}))
+	defer srv.Close()
+
+	clock := NewFakeClock(clockStart)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClock(clock), WithRetryPolicy(RetryPolicy{}),
+		WithTokenSource(deadlineTokenSource{}), WithStaleIfError(CacheChannel, 10*time.Minute))
+
+	if _, err := c.GetChannelByName("AgueMort"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(5 * time.Minute)
+	atomic.StoreInt32(&hang, 1)
+
+	// The token source cannot be asked for the cache key once the deadline has passed
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		channel, err := c.GetChannelByNameContext(ctx, "AgueMort")
+		cancel()
+		if channel == nil || !errors.Is(err, ErrStale) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("call %d: expected a stale response for a caller deadline; got: %v", i, err)
+		}
+	}
+}
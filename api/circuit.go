@@ -0,0 +1,309 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CircuitState
+//
+// The state of a client's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets a few probing requests through to find out whether Picarto has recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// CircuitBreaker
+//
+// Decides when a client stops calling Picarto. A request fails when it cannot be sent, times out or gets a 5xx status
+// once its retries are used up; any other answer is a success.
+type CircuitBreaker struct {
+	// ConsecutiveFailures trips the breaker after that many failures in a row; 0 disables the check
+	ConsecutiveFailures int
+	// FailureRate trips the breaker when at least that fraction (0-1) of the requests in Window failed; 0 disables
+	// the check
+	FailureRate float64
+	// MinRequests is how many requests Window must hold before FailureRate is checked
+	MinRequests int
+	// Window is how long requests count towards FailureRate
+	Window time.Duration
+	// OpenTimeout is how long the breaker stays open before probing
+	OpenTimeout time.Duration
+	// HalfOpenRequests is how many probes may run at once, and how many must succeed to close the breaker again
+	HalfOpenRequests int
+}
+
+// DefaultCircuitBreaker fills in the fields left at zero in the configuration given to WithCircuitBreaker. The two
+// trip conditions are only filled in when both are zero.
+var DefaultCircuitBreaker = CircuitBreaker{
+	ConsecutiveFailures: 5,
+	FailureRate:         0.5,
+	MinRequests:         20,
+	Window:              1 * time.Minute,
+	OpenTimeout:         30 * time.Second,
+	HalfOpenRequests:    1,
+}
+
+// CircuitTransition
+//
+// Reported to the circuit observer whenever the breaker changes state
+type CircuitTransition struct {
+	From CircuitState
+	To   CircuitState
+	At   time.Time
+}
+
+// outcome is how a request counts towards the breaker
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	// outcomeIgnored is a request abandoned by its caller, which says nothing about Picarto
+	outcomeIgnored
+)
+
+type circuitBreaker struct {
+	sync.Mutex
+
+	config   CircuitBreaker
+	clock    Clock
+	observer func(CircuitTransition)
+
+	state    CircuitState
+	openedAt time.Time
+
+	consecutive int
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	probes    int
+	successes int
+}
+
+func newCircuitBreaker(config CircuitBreaker) *circuitBreaker {
+	if config.ConsecutiveFailures == 0 && config.FailureRate == 0 {
+		config.ConsecutiveFailures = DefaultCircuitBreaker.ConsecutiveFailures
+		config.FailureRate = DefaultCircuitBreaker.FailureRate
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = DefaultCircuitBreaker.MinRequests
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultCircuitBreaker.Window
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultCircuitBreaker.OpenTimeout
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = DefaultCircuitBreaker.HalfOpenRequests
+	}
+
+	return &circuitBreaker{config: config, clock: realClock{}}
+}
+
+// State returns the current state, moving an open breaker to half-open once its timeout has passed
+func (b *circuitBreaker) State() CircuitState {
+	b.Lock()
+	transition, changed := b.advance(b.clock.Now())
+	state := b.state
+	b.Unlock()
+
+	if changed {
+		b.notify(transition)
+	}
+
+	return state
+}
+
+// allow reports whether a request may be sent, and whether it is one of the half-open probes
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	b.Lock()
+	transition, changed := b.advance(b.clock.Now())
+
+	switch b.state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			err = ErrCircuitOpen
+		} else {
+			b.probes++
+			probe = true
+		}
+	}
+	b.Unlock()
+
+	if changed {
+		b.notify(transition)
+	}
+
+	return probe, err
+}
+
+// record counts the outcome of a request let through by allow
+func (b *circuitBreaker) record(probe bool, result outcome) {
+	b.Lock()
+	now := b.clock.Now()
+
+	var transition CircuitTransition
+	var changed bool
+
+	if probe {
+		b.probes--
+	}
+
+	switch {
+	case result == outcomeIgnored:
+	case probe && b.state == CircuitHalfOpen:
+		if result == outcomeFailure {
+			transition, changed = b.to(CircuitOpen, now)
+			break
+		}
+
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			transition, changed = b.to(CircuitClosed, now)
+		}
+	case b.state == CircuitClosed:
+		if now.Sub(b.windowStart) >= b.config.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+
+		if result == outcomeSuccess {
+			b.consecutive = 0
+			break
+		}
+		b.failures++
+		b.consecutive++
+
+		if b.tripped() {
+			transition, changed = b.to(CircuitOpen, now)
+		}
+	}
+	b.Unlock()
+
+	if changed {
+		b.notify(transition)
+	}
+}
+
+// tripped reports whether the failures counted so far should open the breaker; the breaker must be locked by the
+// caller
+func (b *circuitBreaker) tripped() bool {
+	if b.config.ConsecutiveFailures > 0 && b.consecutive >= b.config.ConsecutiveFailures {
+		return true
+	}
+
+	return b.config.FailureRate > 0 && b.requests >= b.config.MinRequests &&
+		float64(b.failures) >= b.config.FailureRate*float64(b.requests)
+}
+
+// advance moves an open breaker to half-open once its timeout has passed; the breaker must be locked by the caller
+func (b *circuitBreaker) advance(now time.Time) (CircuitTransition, bool) {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.config.OpenTimeout {
+		return b.to(CircuitHalfOpen, now)
+	}
+
+	return CircuitTransition{}, false
+}
+
+// to changes the state and resets the counters kept for it; the breaker must be locked by the caller
+func (b *circuitBreaker) to(state CircuitState, now time.Time) (CircuitTransition, bool) {
+	transition := CircuitTransition{From: b.state, To: state, At: now}
+
+	b.state = state
+	b.successes = 0
+	switch state {
+	case CircuitOpen:
+		b.openedAt = now
+	case CircuitClosed:
+		b.consecutive = 0
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+
+	return transition, true
+}
+
+// notify reports a transition to the observer; it must not be called with the breaker locked
+func (b *circuitBreaker) notify(transition CircuitTransition) {
+	if b.observer != nil {
+		b.observer(transition)
+	}
+}
+
+// CircuitState returns the state of the client's circuit breaker; CircuitClosed when it has none
+func (c *Client) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+
+	return c.breaker.State()
+}
+
+// guardedRequest makes a single request through the circuit breaker, if the client has one
+func (c *Client) guardedRequest(ctx context.Context, method, route, contentType string, body []byte,
+	bearer string) (*http.Response, error) {
+	if c.breaker == nil {
+		return c.requestWithLockedBucket(ctx, method, route, contentType, body, bearer)
+	}
+
+	probe, err := c.breaker.allow()
+	if err != nil {
+		c.logger.Debug("picarto: circuit open", logKeyEndpoint, strings.TrimPrefix(route, c.baseURL))
+		return nil, err
+	}
+
+	resp, err := c.requestWithLockedBucket(ctx, method, route, contentType, body, bearer)
+
+	result := outcomeSuccess
+	switch {
+	case err != nil && ctx.Err() != nil:
+		result = outcomeIgnored
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		result = outcomeFailure
+	}
+	c.breaker.record(probe, result)
+
+	return resp, err
+}
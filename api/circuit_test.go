@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var status int32 = http.StatusServiceUnavailable
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if s := int(atomic.LoadInt32(&status)); s != http.StatusOK {
+			// The fake clock never reaches the next window, so the budget must come from the headers
+			w.Header().Set("x-ratelimit-remaining", "100")
+			w.WriteHeader(s)
+			return
+		}
+		writeFixture(w, categoriesFixture)
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var transitions []CircuitState
+	clock := NewFakeClock(clockStart)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClock(clock), WithRetryPolicy(RetryPolicy{}),
+		WithCircuitBreaker(CircuitBreaker{ConsecutiveFailures: 3, OpenTimeout: 30 * time.Second}),
+		WithCircuitObserver(func(tr CircuitTransition) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, tr.To)
+		}))
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetCategories(); !errors.Is(err, ErrServerError) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if c.CircuitState() != CircuitOpen {
+		t.Fatalf("breaker did not trip; state: %s", c.CircuitState())
+	}
+
+	// While open, requests fail without reaching the server
+	if _, err := c.GetCategories(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrCircuitOpen, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("open breaker let a request through; request count: %d", n)
+	}
+
+	// A failed probe opens it again
+	clock.Advance(30 * time.Second)
+	if _, err := c.GetCategories(); !errors.Is(err, ErrServerError) {
+		t.Fatalf("probe was not sent: %v", err)
+	}
+	if c.CircuitState() != CircuitOpen {
+		t.Fatalf("failed probe did not reopen the breaker; state: %s", c.CircuitState())
+	}
+
+	// A successful probe closes it
+	atomic.StoreInt32(&status, http.StatusOK)
+	clock.Advance(30 * time.Second)
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+	if c.CircuitState() != CircuitClosed {
+		t.Errorf("successful probe did not close the breaker; state: %s", c.CircuitState())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if !reflect.DeepEqual(transitions, want) {
+		t.Errorf("unexpected transitions; expected: %v, got: %v", want, transitions)
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreaker{FailureRate: 0.5, MinRequests: 4})
+	b.clock = NewFakeClock(clockStart)
+
+	// Alternating results never make three failures in a row, but half of them failed
+	for _, result := range []outcome{outcomeSuccess, outcomeFailure, outcomeSuccess} {
+		b.record(false, result)
+	}
+	if b.State() != CircuitClosed {
+		t.Fatal("breaker tripped before MinRequests was reached")
+	}
+
+	b.record(false, outcomeFailure)
+	if b.State() != CircuitOpen {
+		t.Errorf("breaker did not trip on the failure rate; state: %s", b.State())
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-remaining", "100")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithCircuitBreaker(CircuitBreaker{ConsecutiveFailures: 1}))
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetChannelByName("nobody"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if c.CircuitState() != CircuitClosed {
+		t.Errorf("client errors tripped the breaker; state: %s", c.CircuitState())
+	}
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gojek/heimdall/v7"
+	"github.com/veteran-software/picarto-api-wrapper/oauth"
+)
+
+// Rest is the default client used by the package-level endpoint functions
+var Rest *Client
+
+// Client
+//
+// A Picarto API client with its own credentials, HTTP client, rate limiter and base URL.
+// Multiple clients may be used concurrently in the same process.
+type Client struct {
+	clientID     string
+	clientSecret *string
+	tokenSource  oauth.TokenSource
+	// cacheNamespace stands in for the token source's user in cache keys
+	cacheNamespace string
+	baseURL        string
+
+	httpClient  heimdall.Doer
+	transport   http.RoundTripper
+	timeout     time.Duration
+	retryPolicy RetryPolicy
+	backoff     heimdall.Backoff
+	onAttempt   func(Attempt)
+
+	rateLimitRetries int
+	logger           Logger
+	clock            Clock
+	limiter          *RateLimiter
+
+	cache    Cache
+	cacheTTL map[CacheFamily]time.Duration
+	maxStale map[CacheFamily]time.Duration
+	flights  flightGroup
+
+	breaker         *circuitBreaker
+	circuitObserver func(CircuitTransition)
+}
+
+// Option configures a Client during construction
+type Option func(*Client)
+
+// WithClientSecret sets the client secret sent as the Bearer token on every request
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithClientSecret(clientSecret string) Option {
+	return func(c *Client) {
+		c.clientSecret = &clientSecret
+	}
+}
+
+// WithTokenSource authenticates requests with the tokens supplied by source instead of the client secret, e.g. a user
+// token from oauth.Config.TokenSource that is refreshed before it expires. A token refused with 401 Unauthorized is
+// replaced and the request retried once, if source implements oauth.TokenRejecter.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithTokenSource(source oauth.TokenSource) Option {
+	return func(c *Client) {
+		c.tokenSource = source
+	}
+}
+
+// WithCacheNamespace identifies the user behind the client's token source in cache keys, e.g. by their user ID.
+// Without it, cached responses are keyed by the access token they were fetched with, so a refreshed token starts
+// with an empty cache.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithCacheNamespace(namespace string) Option {
+	return func(c *Client) {
+		c.cacheNamespace = namespace
+	}
+}
+
+// WithBaseURL overrides the API base URL, e.g. to point the client at a local httptest.Server.
+// The URL must include any version prefix, as in "https://api.picarto.tv/api/v1".
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client with doer; an *http.Client satisfies heimdall.Doer.
+// The retry policy still applies on top of it, and WithTimeout has no effect on it.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithHTTPClient(doer heimdall.Doer) Option {
+	return func(c *Client) {
+		c.httpClient = doer
+	}
+}
+
+// WithTransport keeps the default HTTP client but sends requests through transport.
+// It is ignored when WithHTTPClient is also given.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithTimeout sets the timeout of each individual HTTP attempt made by the default HTTP client
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithBackoff sets the strategy used to space out retries, e.g. heimdall.NewConstantBackoff
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithBackoff(backoff heimdall.Backoff) Option {
+	return func(c *Client) {
+		c.backoff = backoff
+	}
+}
+
+// WithAttemptHook registers a function that is called after every HTTP attempt, including retries
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithAttemptHook(hook func(Attempt)) Option {
+	return func(c *Client) {
+		c.onAttempt = hook
+	}
+}
+
+// WithRateLimitRetries sets how many 429 responses are waited out and retried before ErrRateLimited is returned;
+// 0 returns the first 429 to the caller immediately
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithRateLimitRetries(retries int) Option {
+	return func(c *Client) {
+		c.rateLimitRetries = retries
+	}
+}
+
+// WithRateLimitStore makes the client draw from the budget kept in store instead of a private in-memory one. Clients
+// sharing a Client-ID should share a store, e.g. a FileRateLimitStore for several processes on one host.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithRateLimitStore(store RateLimitStore) Option {
+	return func(c *Client) {
+		c.limiter.store = store
+	}
+}
+
+// WithRateLimitObserver registers a function that is called whenever the rate-limit state changes or a request is
+// delayed by the limiter. It is called synchronously, so it should return quickly.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithRateLimitObserver(observer func(RateLimitEvent)) Option {
+	return func(c *Client) {
+		c.limiter.observer = observer
+	}
+}
+
+// WithPriorityReserve makes requests at priority p leave fraction (0-1) of the per-minute limit untouched, so that
+// higher priorities still have budget when p is busy, e.g. WithPriorityReserve(PriorityBackground, 0.25).
+// PriorityInteractive has no higher priority to leave budget for, so it cannot be given a reserve. A negative fraction
+// is treated as 0, and however large the fraction, p may still spend the last token of a full budget.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithPriorityReserve(p Priority, fraction float64) Option {
+	return func(c *Client) {
+		if p < PriorityBackground || p >= PriorityInteractive {
+			return
+		}
+		c.limiter.reserved[p.index()] = math.Max(fraction, 0)
+	}
+}
+
+// WithClock replaces the system clock used for rate-limit, retry and backoff timing, e.g. with a FakeClock in tests
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithLogger sends the client's diagnostics to logger; a *slog.Logger may be passed directly.
+// By default nothing is logged.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithCache serves repeated GET requests from cache, using DefaultCacheTTLs unless WithCacheTTL says otherwise
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithCache(cache Cache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithCacheTTL sets how long responses of family are cached; 0 disables caching for it. Caching is enabled with a
+// default MemoryCache if WithCache is not given.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithCacheTTL(family CacheFamily, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cacheTTL[family] = ttl
+		if c.cache == nil {
+			c.cache = NewMemoryCache(0)
+		}
+	}
+}
+
+// WithStaleIfError makes calls to family return the last good response, at most maxStale past its expiry, when
+// Picarto answers 502, 503 or 504 or times out. The response comes with a *StaleError holding its age and the failure.
+// Caching is enabled with a default MemoryCache if WithCache is not given.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithStaleIfError(family CacheFamily, maxStale time.Duration) Option {
+	return func(c *Client) {
+		c.maxStale[family] = maxStale
+		if c.cache == nil {
+			c.cache = NewMemoryCache(0)
+		}
+	}
+}
+
+// WithCircuitBreaker makes the client fail fast with ErrCircuitOpen once Picarto looks down, as decided by config.
+// Fields left at zero take their value from DefaultCircuitBreaker, e.g. WithCircuitBreaker(CircuitBreaker{}).
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithCircuitBreaker(config CircuitBreaker) Option {
+	return func(c *Client) {
+		c.breaker = newCircuitBreaker(config)
+	}
+}
+
+// WithCircuitObserver registers a function that is called whenever the circuit breaker changes state. It is called
+// synchronously, so it should return quickly.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithCircuitObserver(observer func(CircuitTransition)) Option {
+	return func(c *Client) {
+		c.circuitObserver = observer
+	}
+}
+
+// NewClient
+//
+// Creates a new, independently configured Picarto client
+func NewClient(clientID string, opts ...Option) *Client {
+	c := &Client{
+		clientID:    clientID,
+		baseURL:     api,
+		timeout:     timeout,
+		retryPolicy: DefaultRetryPolicy,
+		backoff:     newBackoff(),
+
+		rateLimitRetries: rateLimitRetries,
+		logger:           nopLogger{},
+		clock:            realClock{},
+		limiter:          newRateLimiter(),
+
+		cacheTTL: make(map[CacheFamily]time.Duration, len(DefaultCacheTTLs)),
+		maxStale: make(map[CacheFamily]time.Duration),
+	}
+
+	for family, ttl := range DefaultCacheTTLs {
+		c.cacheTTL[family] = ttl
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.limiter.clock = c.clock
+	if c.breaker != nil {
+		c.breaker.clock = c.clock
+		c.breaker.observer = c.circuitObserver
+	}
+
+	if c.logger == nil {
+		c.logger = nopLogger{}
+	}
+	if c.httpClient == nil {
+		c.httpClient = newHTTPClient(c.timeout, c.transport)
+	}
+
+	return c
+}
+
+// RateLimit returns a snapshot of the client's rate-limit budget
+func (c *Client) RateLimit() RateLimit {
+	return c.limiter.State()
+}
+
+// ClockSkew returns the estimated offset of Picarto's clock from the local clock; positive when Picarto is ahead
+func (c *Client) ClockSkew() time.Duration {
+	return c.limiter.State().ClockSkew
+}
+
+// NewPicarto
+//
+// Creates a new client with the given client ID and optional client secret.
+// Assign the result to Rest to use the package-level endpoint functions.
+func NewPicarto(clientId string, clientSecret ...string) *Client {
+	var opts []Option
+	if len(clientSecret) == 1 {
+		opts = append(opts, WithClientSecret(clientSecret[0]))
+	}
+
+	return NewClient(clientId, opts...)
+}
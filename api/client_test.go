@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import "testing"
+
+func TestNewClientIsolation(t *testing.T) {
+	a := NewClient("CLIENT_A", WithClientSecret("SECRET_A"))
+	b := NewClient("CLIENT_B")
+
+	if a.clientID != "CLIENT_A" || b.clientID != "CLIENT_B" {
+		t.Errorf("unexpected client IDs; got: %q and %q", a.clientID, b.clientID)
+	}
+	if a.clientSecret == nil || *a.clientSecret != "SECRET_A" {
+		t.Error("client secret was not set on the first client")
+	}
+	if b.clientSecret != nil {
+		t.Error("client secret leaked into the second client")
+	}
+	if a.limiter == b.limiter {
+		t.Error("clients share a rate limiter")
+	}
+}
+
+func TestNewPicarto(t *testing.T) {
+	c := NewPicarto("CLIENT_ID", "CLIENT_SECRET")
+
+	if c.clientID != "CLIENT_ID" {
+		t.Errorf("unexpected client ID; got: %q", c.clientID)
+	}
+	if c.clientSecret == nil || *c.clientSecret != "CLIENT_SECRET" {
+		t.Error("client secret was not set")
+	}
+	if c.baseURL != api {
+		t.Errorf("unexpected base URL; got: %q", c.baseURL)
+	}
+}
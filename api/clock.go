@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock
+//
+// The source of time for a client and its rate limiter. The default reads the system clock; tests can pass a
+// FakeClock with WithClock to control time.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of *time.Timer used by the client
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// sleepContext blocks for d on clock or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// FakeClock
+//
+// A Clock that only moves when told to. Timers fire once Advance or Set moves the clock past their deadline.
+type FakeClock struct {
+	sync.Mutex
+
+	now    time.Time
+	timers []*fakeTimer
+	// changed is closed and replaced whenever a timer is created, so tests can wait for a goroutine to block
+	changed chan struct{}
+}
+
+// NewFakeClock creates a FakeClock reading now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now:     now,
+		changed: make(chan struct{}),
+	}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.Lock()
+	defer c.Unlock()
+
+	t := &fakeTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		ch:       make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		t.ch <- c.now
+	} else {
+		c.timers = append(c.timers, t)
+	}
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer whose deadline has been reached
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to now, firing every timer whose deadline has been reached
+func (c *FakeClock) Set(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.set(now)
+}
+
+func (c *FakeClock) set(now time.Time) {
+	c.now = now
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if now.Before(t.deadline) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- now
+	}
+	c.timers = pending
+}
+
+// Timers returns the number of timers that have not fired or been stopped yet
+func (c *FakeClock) Timers() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil waits until at least n timers are pending, i.e. until n goroutines are sleeping on the clock
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.Lock()
+		pending, changed := len(c.timers), c.changed
+		c.Unlock()
+
+		if pending >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.Lock()
+	defer t.clock.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
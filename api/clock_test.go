@@ -0,0 +1,307 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var clockStart = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFakeClockTimers(t *testing.T) {
+	clock := NewFakeClock(clockStart)
+
+	early := clock.NewTimer(1 * time.Second)
+	late := clock.NewTimer(1 * time.Minute)
+	stopped := clock.NewTimer(2 * time.Second)
+
+	if !stopped.Stop() {
+		t.Error("stopping a pending timer should report true")
+	}
+
+	clock.Advance(30 * time.Second)
+
+	select {
+	case at := <-early.C():
+		if !at.Equal(clockStart.Add(30 * time.Second)) {
+			t.Errorf("unexpected fire time: %s", at)
+		}
+	default:
+		t.Error("timer did not fire after its deadline")
+	}
+
+	select {
+	case <-late.C():
+		t.Error("timer fired before its deadline")
+	case <-stopped.C():
+		t.Error("stopped timer fired")
+	default:
+	}
+
+	if clock.Timers() != 1 {
+		t.Errorf("unexpected pending timers; expected: 1, got: %d", clock.Timers())
+	}
+}
+
+func TestReserveWaitsForMinuteBoundary(t *testing.T) {
+	clock := NewFakeClock(clockStart.Add(59*time.Second + 500*time.Millisecond))
+	c := NewClient("TEST_TOKEN", WithClock(clock))
+	setState(c.limiter, RateLimitState{Limit: 150, Remaining: 0, Reset: clockStart.Add(1 * time.Minute)})
+
+	done := make(chan time.Duration, 1)
+	go func() {
+		waited, err := c.limiter.reserve(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		done <- waited
+	}()
+
+	clock.BlockUntil(1)
+
+	// Not quite at the boundary yet
+	clock.Advance(499 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("reservation was granted before the reset")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(1 * time.Millisecond)
+	if waited := <-done; waited != 500*time.Millisecond {
+		t.Errorf("unexpected wait; expected: 500ms, got: %s", waited)
+	}
+
+	state := c.RateLimit()
+	if state.Remaining != 149 || !state.Reset.Equal(clockStart.Add(2*time.Minute)) {
+		t.Errorf("unexpected state after the refill: %+v", state)
+	}
+}
+
+func TestReleaseDateRounding(t *testing.T) {
+	tests := map[string]time.Time{
+		// Under 30s the minute is bumped before rounding
+		"Sun, 01 Jan 2023 12:00:00 GMT": clockStart.Add(1 * time.Minute),
+		"Sun, 01 Jan 2023 12:00:29 GMT": clockStart.Add(1 * time.Minute),
+		// From 30s on rounding alone reaches the next minute
+		"Sun, 01 Jan 2023 12:00:30 GMT": clockStart.Add(1 * time.Minute),
+		"Sun, 01 Jan 2023 12:00:59 GMT": clockStart.Add(1 * time.Minute),
+	}
+
+	for date, want := range tests {
+		// Keep the local clock in step with the Date header so no skew is applied
+		local, _ := time.Parse(time.RFC1123, date)
+		r := newRateLimiter()
+		r.clock = NewFakeClock(local.Add(500 * time.Millisecond))
+
+		if _, err := r.reserve(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.release(http.Header{"Date": []string{date}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := r.State().Reset; !got.Equal(want) {
+			t.Errorf("Date %q; expected reset: %s, got: %s", date, want, got)
+		}
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	clock := NewFakeClock(clockStart.Add(10 * time.Second))
+	r := newRateLimiter()
+	r.clock = clock
+
+	// Picarto's clock reads 12:00:13 while ours reads 12:00:10
+	if _, err := r.reserve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.release(http.Header{
+		"Date":                  []string{"Sun, 01 Jan 2023 12:00:13 GMT"},
+		"X-Ratelimit-Remaining": []string{"100"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	state := r.State()
+	if state.ClockSkew != 3500*time.Millisecond {
+		t.Errorf("unexpected skew; expected: 3.5s, got: %s", state.ClockSkew)
+	}
+	// Picarto resets at 12:01:00 on its own clock, which is 3.5s earlier on ours
+	if want := clockStart.Add(56500 * time.Millisecond); !state.Reset.Equal(want) {
+		t.Errorf("unexpected reset; expected: %s, got: %s", want, state.Reset)
+	}
+
+	// Later samples move the estimate gradually instead of replacing it
+	if _, err := r.reserve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.release(http.Header{"Date": []string{"Sun, 01 Jan 2023 12:00:10 GMT"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.State().ClockSkew; got != 3500*time.Millisecond-3*time.Second/skewSmoothing {
+		t.Errorf("unexpected smoothed skew; got: %s", got)
+	}
+}
+
+func TestRateLimitedWaitsForReset(t *testing.T) {
+	clock := NewFakeClock(clockStart.Add(45 * time.Second))
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server's clock has to agree with the fake one
+		w.Header().Set("Date", clock.Now().Format(http.TimeFormat))
+
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeFixture(w, categoriesFixture)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClock(clock))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetCategories()
+		done <- err
+	}()
+
+	// Without Retry-After the client sleeps until the top of the minute
+	clock.BlockUntil(1)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("unexpected number of requests before the reset; expected: 1, got: %d", n)
+	}
+
+	clock.Advance(15 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of requests; expected: 2, got: %d", calls)
+	}
+}
+
+func TestThrottledWindowProbes(t *testing.T) {
+	clock := NewFakeClock(clockStart.Add(10*time.Second + 500*time.Millisecond))
+	r := newRateLimiter()
+	r.clock = clock
+	setState(r, RateLimitState{Limit: 100, Remaining: 50, Reset: clockStart.Add(1 * time.Minute)})
+
+	// A short Retry-After ends in the middle of Picarto's window
+	r.exhaust(clock.Now().Add(1 * time.Second))
+	clock.Advance(1 * time.Second)
+
+	if _, err := r.reserve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if state := r.State(); state.Remaining != 0 {
+		t.Fatalf("only a single probe should follow a 429; remaining: %d", state.Remaining)
+	}
+
+	// The probe's response tells us the real budget
+	if err := r.release(http.Header{
+		"Date":                  []string{"Sun, 01 Jan 2023 12:00:11 GMT"},
+		"X-Ratelimit-Remaining": []string{"40"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if state := r.State(); state.Remaining != 40 || !state.Reset.Equal(clockStart.Add(1*time.Minute)) {
+		t.Errorf("unexpected state after the probe: %+v", state)
+	}
+}
+
+func TestThrottledDeadlineSurvivesRelease(t *testing.T) {
+	clock := NewFakeClock(clockStart.Add(10*time.Second + 500*time.Millisecond))
+	r := newRateLimiter()
+	r.clock = clock
+	setState(r, RateLimitState{Limit: 100, Remaining: 50, Reset: clockStart.Add(1 * time.Minute)})
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.reserve(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A Retry-After reaching past the top of the minute
+	deadline := clockStart.Add(90 * time.Second)
+	r.exhaust(deadline)
+
+	// A response from the throttled window leaves the deadline alone
+	if err := r.release(http.Header{
+		"Date":                  []string{"Sun, 01 Jan 2023 12:00:10 GMT"},
+		"X-Ratelimit-Remaining": []string{"0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if state := r.State(); !state.Reset.Equal(deadline) || state.Remaining != 0 {
+		t.Errorf("the Retry-After deadline was lost: %+v", state)
+	}
+
+	// One from a later window ends it
+	clock.Advance(55 * time.Second)
+	if err := r.release(http.Header{
+		"Date":                  []string{"Sun, 01 Jan 2023 12:01:05 GMT"},
+		"X-Ratelimit-Remaining": []string{"99"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if state := r.State(); !state.Reset.Equal(clockStart.Add(2*time.Minute)) || state.Remaining != 99 {
+		t.Errorf("a new window did not end the throttle: %+v", state)
+	}
+}
+
+func TestReleaseBudgetOnlyShrinksDespiteSkew(t *testing.T) {
+	clock := NewFakeClock(clockStart.Add(10 * time.Second))
+	r := newRateLimiter()
+	r.clock = clock
+	setState(r, RateLimitState{Limit: 100, Remaining: 100, Reset: clockStart.Add(1 * time.Minute)})
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.reserve(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each response nudges the skew estimate, but all of them come from the same window
+	var budgets []int
+	for i, remaining := range []string{"50", "40", "45"} {
+		clock.Advance(time.Duration(i*37) * time.Millisecond)
+		if err := r.release(http.Header{
+			"Date":                  []string{"Sun, 01 Jan 2023 12:00:10 GMT"},
+			"X-Ratelimit-Remaining": []string{remaining},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		budgets = append(budgets, r.State().Remaining)
+	}
+
+	// 2 and 1 tokens stay reserved for the requests still in flight; the late 45 is ignored
+	if want := []int{48, 39, 39}; !reflect.DeepEqual(budgets, want) {
+		t.Errorf("unexpected budgets; expected: %v, got: %v", want, budgets)
+	}
+	if reset := r.State().Reset; reset.Sub(clockStart.Add(1*time.Minute)).Abs() > 1*time.Second {
+		t.Errorf("unexpected reset: %s", reset)
+	}
+}
@@ -0,0 +1,235 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import "context"
+
+// GetCategories
+//
+// Calls Client.GetCategories on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetCategories() ([]Category, error) {
+	return Rest.GetCategories()
+}
+
+// GetCategoriesContext
+//
+// Calls Client.GetCategoriesContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetCategoriesContext(ctx context.Context) ([]Category, error) {
+	return Rest.GetCategoriesContext(ctx)
+}
+
+// GetChannelByID
+//
+// Calls Client.GetChannelByID on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetChannelByID(channelID int) (*Channel, error) {
+	return Rest.GetChannelByID(channelID)
+}
+
+// GetChannelByIDContext
+//
+// Calls Client.GetChannelByIDContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetChannelByIDContext(ctx context.Context, channelID int) (*Channel, error) {
+	return Rest.GetChannelByIDContext(ctx, channelID)
+}
+
+// GetChannelByName
+//
+// Calls Client.GetChannelByName on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetChannelByName(channelName string) (*Channel, error) {
+	return Rest.GetChannelByName(channelName)
+}
+
+// GetChannelByNameContext
+//
+// Calls Client.GetChannelByNameContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetChannelByNameContext(ctx context.Context, channelName string) (*Channel, error) {
+	return Rest.GetChannelByNameContext(ctx, channelName)
+}
+
+// GetAllChannelVideosByChannelID
+//
+// Calls Client.GetAllChannelVideosByChannelID on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetAllChannelVideosByChannelID(channelID int) ([]Video, error) {
+	return Rest.GetAllChannelVideosByChannelID(channelID)
+}
+
+// GetAllChannelVideosByChannelIDContext
+//
+// Calls Client.GetAllChannelVideosByChannelIDContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetAllChannelVideosByChannelIDContext(ctx context.Context, channelID int) ([]Video, error) {
+	return Rest.GetAllChannelVideosByChannelIDContext(ctx, channelID)
+}
+
+// GetAllChannelVideosByChannelName
+//
+// Calls Client.GetAllChannelVideosByChannelName on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetAllChannelVideosByChannelName(channelName string) ([]Video, error) {
+	return Rest.GetAllChannelVideosByChannelName(channelName)
+}
+
+// GetAllChannelVideosByChannelNameContext
+//
+// Calls Client.GetAllChannelVideosByChannelNameContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetAllChannelVideosByChannelNameContext(ctx context.Context, channelName string) ([]Video, error) {
+	return Rest.GetAllChannelVideosByChannelNameContext(ctx, channelName)
+}
+
+// GetOnline
+//
+// Calls Client.GetOnline on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetOnline(query OnlineQuery) ([]Online, error) {
+	return Rest.GetOnline(query)
+}
+
+// GetOnlineContext
+//
+// Calls Client.GetOnlineContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetOnlineContext(ctx context.Context, query OnlineQuery) ([]Online, error) {
+	return Rest.GetOnlineContext(ctx, query)
+}
+
+// SearchChannels
+//
+// Calls Client.SearchChannels on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func SearchChannels(query ChannelSearchQuery) ([]Channel, error) {
+	return Rest.SearchChannels(query)
+}
+
+// SearchChannelsContext
+//
+// Calls Client.SearchChannelsContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func SearchChannelsContext(ctx context.Context, query ChannelSearchQuery) ([]Channel, error) {
+	return Rest.SearchChannelsContext(ctx, query)
+}
+
+// SearchVideos
+//
+// Calls Client.SearchVideos on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func SearchVideos(query VideoSearchQuery) ([]Video, error) {
+	return Rest.SearchVideos(query)
+}
+
+// SearchVideosContext
+//
+// Calls Client.SearchVideosContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func SearchVideosContext(ctx context.Context, query VideoSearchQuery) ([]Video, error) {
+	return Rest.SearchVideosContext(ctx, query)
+}
+
+// SearchChannelsPager
+//
+// Calls Client.SearchChannelsPager on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func SearchChannelsPager(query ChannelSearchQuery, maxResults int) *Pager[Channel] {
+	return Rest.SearchChannelsPager(query, maxResults)
+}
+
+// SearchVideosPager
+//
+// Calls Client.SearchVideosPager on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func SearchVideosPager(query VideoSearchQuery, maxResults int) *Pager[Video] {
+	return Rest.SearchVideosPager(query, maxResults)
+}
+
+// GetStreamByChannelID
+//
+// Calls Client.GetStreamByChannelID on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetStreamByChannelID(channelID int) (*Stream, error) {
+	return Rest.GetStreamByChannelID(channelID)
+}
+
+// GetStreamByChannelIDContext
+//
+// Calls Client.GetStreamByChannelIDContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetStreamByChannelIDContext(ctx context.Context, channelID int) (*Stream, error) {
+	return Rest.GetStreamByChannelIDContext(ctx, channelID)
+}
+
+// GetStreamByChannelName
+//
+// Calls Client.GetStreamByChannelName on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetStreamByChannelName(channelName string) (*Stream, error) {
+	return Rest.GetStreamByChannelName(channelName)
+}
+
+// GetStreamByChannelNameContext
+//
+// Calls Client.GetStreamByChannelNameContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetStreamByChannelNameContext(ctx context.Context, channelName string) (*Stream, error) {
+	return Rest.GetStreamByChannelNameContext(ctx, channelName)
+}
+
+// GetNotifications
+//
+// Calls Client.GetNotifications on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetNotifications() (*Notification, error) {
+	return Rest.GetNotifications()
+}
+
+// GetNotificationsContext
+//
+// Calls Client.GetNotificationsContext on the default client (Rest)
+//
+//goland:noinspection GoUnusedExportedFunction
+func GetNotificationsContext(ctx context.Context) (*Notification, error) {
+	return Rest.GetNotificationsContext(ctx)
+}
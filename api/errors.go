@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNotFound is matched by an *APIError with status 404 Not Found
+	ErrNotFound = errors.New("picarto: not found")
+	// ErrUnauthorized is matched by an *APIError with status 401 Unauthorized
+	ErrUnauthorized = errors.New("picarto: unauthorized")
+	// ErrForbidden is matched by an *APIError with status 403 Forbidden
+	ErrForbidden = errors.New("picarto: forbidden")
+	// ErrRateLimited is matched by an *APIError with status 429 Too Many Requests
+	ErrRateLimited = errors.New("picarto: rate limited")
+	// ErrServerError is matched by an *APIError with any 5xx status
+	ErrServerError = errors.New("picarto: server error")
+
+	// ErrDecode wraps failures to decode a response body
+	ErrDecode = errors.New("picarto: unable to decode response")
+	// ErrEmptyQuery is returned by the search endpoints when no query is given
+	ErrEmptyQuery = errors.New("picarto: search query must not be empty")
+	// ErrInvalidChannelName is matched by a *ChannelNameError
+	ErrInvalidChannelName = errors.New("picarto: invalid channel name")
+	// ErrCircuitOpen is returned without sending the request while the client's circuit breaker is open
+	ErrCircuitOpen = errors.New("picarto: circuit breaker is open")
+	// ErrStale is matched by a *StaleError
+	ErrStale = errors.New("picarto: serving stale response")
+	// ErrInsufficientScope is matched by a *ScopeError
+	ErrInsufficientScope = errors.New("picarto: insufficient scope")
+)
+
+// maxErrorBody caps how much of an error response body is kept on an APIError
+const maxErrorBody = 64 << 10
+
+// APIError
+//
+// Returned when Picarto answers with a non-success status code
+type APIError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	Endpoint   string
+	Body       []byte
+
+	// Rate-limit state reported alongside the response; -1 when the header was absent
+	RateLimitLimit     int
+	RateLimitRemaining int
+	// RetryAfter is the parsed Retry-After header; 0 when the header was absent
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("picarto: %s %s: %s", e.Method, e.Endpoint, e.Status)
+}
+
+// Is allows errors.Is to match an APIError against the sentinel errors of this package
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+
+	return false
+}
+
+// StaleError
+//
+// Returned alongside the last good response when Picarto could not be reached and stale-if-error is enabled for the
+// endpoint. The endpoint's result is valid, but Age old; Err is the failure that forced the fallback.
+type StaleError struct {
+	Age time.Duration
+	Err error
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("picarto: serving %s old response: %v", e.Age, e.Err)
+}
+
+// Is allows errors.Is to match a StaleError against ErrStale
+func (e *StaleError) Is(target error) bool {
+	return target == ErrStale
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError builds an APIError from resp received at now and closes its body
+func newAPIError(resp *http.Response, now time.Time) *APIError {
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	e := &APIError{
+		StatusCode:         resp.StatusCode,
+		Status:             resp.Status,
+		Body:               body,
+		RateLimitLimit:     headerInt(resp.Header, "x-ratelimit-limit"),
+		RateLimitRemaining: headerInt(resp.Header, "x-ratelimit-remaining"),
+		RetryAfter:         parseRetryAfter(resp.Header.Get("Retry-After"), now),
+	}
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Endpoint = resp.Request.URL.Path
+	}
+
+	return e
+}
+
+// decodeError wraps a JSON decode failure of resp so that it matches ErrDecode
+func decodeError(resp *http.Response, err error) error {
+	if resp.Request == nil {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	return fmt.Errorf("%w from %s: %w", ErrDecode, resp.Request.URL.Path, err)
+}
+
+func headerInt(headers http.Header, key string) int {
+	v := headers.Get(key)
+	if v == "" {
+		return -1
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return -1
+	}
+
+	return i
+}
+
+// parseRetryAfter understands both the delay-seconds and HTTP-date forms of Retry-After
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		status int
+		target error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusBadGateway, ErrServerError},
+		{http.StatusServiceUnavailable, ErrServerError},
+	}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: tt.status})
+
+		if !errors.Is(err, tt.target) {
+			t.Errorf("status %d does not match %v", tt.status, tt.target)
+		}
+		if errors.Is(err, ErrDecode) {
+			t.Errorf("status %d unexpectedly matches %v", tt.status, ErrDecode)
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Errorf("errors.As failed for status %d", tt.status)
+		}
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Status:     "429 Too Many Requests",
+		Header: http.Header{
+			"X-Ratelimit-Limit":     []string{"150"},
+			"X-Ratelimit-Remaining": []string{"0"},
+			"Retry-After":           []string{"12"},
+		},
+		Body:    io.NopCloser(strings.NewReader(`{"message":"slow down"}`)),
+		Request: &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/api/v1/online"}},
+	}
+
+	e := newAPIError(resp, time.Now())
+
+	if e.Method != http.MethodGet || e.Endpoint != "/api/v1/online" {
+		t.Errorf("unexpected endpoint; got: %s %s", e.Method, e.Endpoint)
+	}
+	if string(e.Body) != `{"message":"slow down"}` {
+		t.Errorf("unexpected body; got: %s", e.Body)
+	}
+	if e.RateLimitLimit != 150 || e.RateLimitRemaining != 0 {
+		t.Errorf("unexpected rate limit; got: %d/%d", e.RateLimitRemaining, e.RateLimitLimit)
+	}
+	if e.RetryAfter != 12*time.Second {
+		t.Errorf("unexpected retry after; got: %s", e.RetryAfter)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-5":                            0,
+		"garbage":                       0,
+		"Sun, 01 Jan 2023 12:00:45 GMT": 45 * time.Second,
+		"Sun, 01 Jan 2023 11:59:00 GMT": 0,
+	}
+
+	for v, want := range tests {
+		if got := parseRetryAfter(v, now); got != want {
+			t.Errorf("parseRetryAfter(%q); expected: %s, got: %s", v, want, got)
+		}
+	}
+}
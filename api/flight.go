@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// flightGroup collapses identical GET requests made at the same time into a single request
+type flightGroup struct {
+	sync.Mutex
+
+	flights map[string]*flight
+}
+
+// flight is a request shared by every caller waiting on it
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// detachedContext keeps the values of its parent, such as the priority, but none of its cancellation, so a shared
+// request outlives the caller that started it
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key any) any {
+	return d.parent.Value(key)
+}
+
+// sharedRequest joins an identical GET request already in flight, or starts one that later callers can join. Each
+// caller stops waiting when its own ctx is done, and is served a stale response if its deadline passed and
+// stale-if-error allows it; the request itself is only cancelled once nobody waits for it.
+func (c *Client) sharedRequest(ctx context.Context, method, route, contentType string, b *interface{}) (*http.Response,
+	error) {
+	if method != http.MethodGet || b != nil {
+		return c.cachedRequest(ctx, "", method, route, contentType, b)
+	}
+
+	// Calls that use the cache or the budget differently must not share a request
+	cacheKey, err := c.cacheKey(ctx, method, route)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s %d %d", cacheKey, cacheModeFrom(ctx), priorityFrom(ctx))
+
+	g := &c.flights
+	g.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f, ok := g.flights[key]
+	if !ok {
+		flightCtx, cancel := context.WithCancel(detachedContext{parent: ctx})
+
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+
+		go c.fly(flightCtx, key, cacheKey, f, method, route, contentType)
+	}
+	f.waiters++
+	g.Unlock()
+
+	select {
+	case <-f.done:
+		return f.response()
+	case <-ctx.Done():
+		g.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			g.forget(key, f)
+		}
+		g.Unlock()
+
+		// A caller that runs out of time may still be given the last good response
+		if stale, staleErr := c.staleResponse(ctx, cacheKey, route, ctx.Err()); staleErr != nil {
+			return stale, staleErr
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+// fly makes the shared request, cached under cacheKey, and keeps its result for every waiter
+func (c *Client) fly(ctx context.Context, key, cacheKey string, f *flight, method, route, contentType string) {
+	defer f.cancel()
+
+	// A stale response comes with an error, so the body is read whenever there is one
+	resp, err := c.cachedRequest(ctx, cacheKey, method, route, contentType, nil)
+	if resp != nil {
+		body, readErr := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if readErr != nil {
+			resp, err = nil, readErr
+		}
+		f.body = body
+	}
+	f.resp, f.err = resp, err
+
+	c.flights.Lock()
+	c.flights.forget(key, f)
+	c.flights.Unlock()
+
+	close(f.done)
+}
+
+// forget removes f unless a newer flight has already taken its place; the group must be locked by the caller
+func (g *flightGroup) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// response gives a waiter its own copy of the shared response
+func (f *flight) response() (*http.Response, error) {
+	if f.resp == nil {
+		return nil, f.err
+	}
+
+	resp := *f.resp
+	resp.Header = f.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(f.body))
+
+	return &resp, f.err
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newBlockingServer serves the channel fixture once release is closed and counts the requests that reach it
+func newBlockingServer(t *testing.T) (srv *httptest.Server, calls *int32, release chan struct{}) {
+	calls, release = new(int32), make(chan struct{})
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		writeFixture(w, channelFixture)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, calls, release
+}
+
+// waitForWaiters blocks until n callers are waiting on flights of c
+func waitForWaiters(t *testing.T, c *Client, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(1 * time.Second)
+	for time.Now().Before(deadline) {
+		c.flights.Lock()
+		var waiters int
+		for _, f := range c.flights.flights {
+			waiters += f.waiters
+		}
+		c.flights.Unlock()
+
+		if waiters >= n {
+			return
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	t.Fatalf("%d callers never started waiting", n)
+}
+
+func TestSharedRequest(t *testing.T) {
+	srv, calls, release := newBlockingServer(t)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	var wg sync.WaitGroup
+	channels := make([]*Channel, 5)
+	for i := range channels {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var err error
+			if channels[i], err = c.GetChannelByName("AgueMort"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	waitForWaiters(t, c, len(channels))
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("identical requests were not collapsed; request count: %d", n)
+	}
+	for _, channel := range channels {
+		if channel != nil {
+			evalChannelFields(t, channel)
+		}
+	}
+}
+
+func TestSharedRequestCallerContext(t *testing.T) {
+	srv, calls, release := newBlockingServer(t)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	// The caller that started the request gives up, but the other waiter still gets the result
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.GetChannelByNameContext(ctx, "AgueMort")
+		first <- err
+	}()
+	waitForWaiters(t, c, 1)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.GetChannelByName("AgueMort")
+		second <- err
+	}()
+	waitForWaiters(t, c, 2)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller was not released; got: %v", err)
+	}
+
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("remaining caller lost the shared request: %v", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("unexpected request count; expected: 1, got: %d", n)
+	}
+}
+
+func TestSharedRequestAbandoned(t *testing.T) {
+	srv, _, _ := newBlockingServer(t)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.GetChannelByNameContext(ctx, "AgueMort"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error; expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+
+	// Once nobody waits, the request is cancelled and its token returned
+	deadline := time.Now().Add(1 * time.Second)
+	for c.RateLimit().InFlight != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned request was not cancelled")
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+// Logger
+//
+// Receives the client's diagnostics as a message plus alternating key/value pairs. The method set matches
+// *slog.Logger, so one can be passed to WithLogger directly.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Structured field keys used in log entries
+const (
+	logKeyEndpoint  = "endpoint"
+	logKeyStatus    = "status"
+	logKeyAttempt   = "attempt"
+	logKeyRemaining = "ratelimit_remaining"
+	logKeyWait      = "wait"
+	logKeyAge       = "age"
+	logKeyError     = "error"
+)
+
+// nopLogger discards everything; it is the default Logger
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gojek/heimdall/v7"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+// recordingLogger keeps every entry so tests can inspect the structured fields
+type recordingLogger struct {
+	sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level, msg string, args []any) {
+	l.Lock()
+	defer l.Unlock()
+
+	fields := make(map[string]any)
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[args[i].(string)] = args[i+1]
+	}
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, fields: fields})
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.record("debug", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.record("info", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.record("warn", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.record("error", msg, args) }
+
+func TestLoggerFields(t *testing.T) {
+	srv, _ := newFlakyServer(t, 1, http.StatusBadGateway)
+
+	logger := &recordingLogger{}
+	c := NewClient("TEST_TOKEN",
+		WithBaseURL(srv.URL),
+		WithBackoff(heimdall.NewConstantBackoff(0, 0)),
+		WithLogger(logger),
+	)
+
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	var retried, received bool
+	for _, e := range logger.entries {
+		if e.fields[logKeyEndpoint] != "/categories" {
+			t.Errorf("%q: unexpected endpoint field; got: %v", e.msg, e.fields[logKeyEndpoint])
+		}
+
+		switch e.level {
+		case "warn":
+			retried = e.fields[logKeyAttempt] == 1 && e.fields[logKeyStatus] == http.StatusBadGateway
+		case "debug":
+			received = e.fields[logKeyRemaining] == 149 && e.fields[logKeyStatus] == http.StatusOK
+		}
+	}
+
+	if !retried {
+		t.Error("retry was not logged with attempt and status fields")
+	}
+	if !received {
+		t.Error("response was not logged with status and rate-limit remaining fields")
+	}
+}
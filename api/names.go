@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Picarto channel names are the account's username
+const (
+	minChannelNameLength = 3
+	maxChannelNameLength = 24
+)
+
+// ChannelNameError
+//
+// Returned, before any request is made, for a channel name that Picarto could never have issued
+type ChannelNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *ChannelNameError) Error() string {
+	return fmt.Sprintf("picarto: invalid channel name %q: %s", e.Name, e.Reason)
+}
+
+// Is allows errors.Is to match a ChannelNameError against ErrInvalidChannelName
+func (e *ChannelNameError) Is(target error) bool {
+	return target == ErrInvalidChannelName
+}
+
+// ValidateChannelName
+//
+// Checks name against Picarto's naming rules: 3 to 24 characters, each an ASCII letter, digit, underscore or hyphen.
+// Returns a *ChannelNameError describing the first rule broken, or nil.
+//
+//goland:noinspection GoUnusedExportedFunction
+func ValidateChannelName(name string) error {
+	if len(name) < minChannelNameLength || len(name) > maxChannelNameLength {
+		return &ChannelNameError{
+			Name:   name,
+			Reason: fmt.Sprintf("must be %d to %d characters long", minChannelNameLength, maxChannelNameLength),
+		}
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return &ChannelNameError{
+				Name:   name,
+				Reason: fmt.Sprintf("must not contain %q", r),
+			}
+		}
+	}
+
+	return nil
+}
+
+// channelNameRoute builds the URL of a /channel/name endpoint, with suffix appended after the escaped name
+func (c *Client) channelNameRoute(channelName, suffix string) (string, error) {
+	if err := ValidateChannelName(channelName); err != nil {
+		return "", err
+	}
+
+	return c.baseURL + "/channel/name/" + url.PathEscape(channelName) + suffix, nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateChannelName(t *testing.T) {
+	tests := map[string]bool{
+		"AgueMort":                  true,
+		"pixel_art-42":              true,
+		"abc":                       true,
+		"abcdefghijklmnopqrstuvwx":  true,
+		"ab":                        false,
+		"abcdefghijklmnopqrstuvwxy": false,
+		"foo/../../online":          false,
+		"name?adult=true":           false,
+		"name#fragment":             false,
+		"white space":               false,
+		"ÄgueMort":                  false,
+		"":                          false,
+	}
+
+	for name, valid := range tests {
+		err := ValidateChannelName(name)
+		if valid && err != nil {
+			t.Errorf("%q was rejected: %v", name, err)
+		}
+		if !valid && !errors.Is(err, ErrInvalidChannelName) {
+			t.Errorf("%q was accepted; got: %v", name, err)
+		}
+	}
+}
+
+func TestInvalidChannelNameSpendsNoBudget(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+	before := c.RateLimit()
+
+	_, err := c.GetChannelByName("foo/../../online")
+	var nameErr *ChannelNameError
+	if !errors.As(err, &nameErr) || nameErr.Name != "foo/../../online" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = c.GetStreamByChannelName("a?b"); !errors.Is(err, ErrInvalidChannelName) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err = c.GetAllChannelVideosByChannelName("a#b"); !errors.Is(err, ErrInvalidChannelName) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("invalid names reached the server %d times", calls)
+	}
+	if after := c.RateLimit(); after != before {
+		t.Errorf("rate-limit budget changed; before: %+v, after: %+v", before, after)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"strconv"
+)
+
+// Pager
+//
+// Walks the pages of a search one result at a time, fetching the next page when the current one is used up. Results
+// that shift onto the next page while walking are only returned once. The walk ends at the first empty page, after
+// maxResults results, or on the first error. Every page is a separate request, so it waits for the rate limiter like
+// any other call.
+//
+//	pager := client.SearchChannelsPager(api.ChannelSearchQuery{Query: "pixel art"}, 100)
+//	for pager.Next(ctx) {
+//		fmt.Println(pager.Item().Name)
+//	}
+//	if err := pager.Err(); err != nil {
+//		return err
+//	}
+type Pager[T any] struct {
+	fetch func(ctx context.Context, page uint64) ([]T, error)
+	key   func(T) string
+
+	page       uint64
+	maxResults int
+	returned   int
+	seen       map[string]struct{}
+
+	buffered []T
+	item     T
+	done     bool
+	err      error
+}
+
+// newPager creates a pager starting at page, identifying results by key; maxResults of 0 or less means no limit
+func newPager[T any](page uint64, maxResults int, key func(T) string,
+	fetch func(ctx context.Context, page uint64) ([]T, error)) *Pager[T] {
+	return &Pager[T]{
+		fetch:      fetch,
+		key:        key,
+		page:       pageOrFirst(page),
+		maxResults: maxResults,
+		seen:       make(map[string]struct{}),
+	}
+}
+
+// Next advances to the next result, fetching the next page with ctx when needed. It returns false once the walk has
+// ended; Err then tells whether it ended because of an error.
+func (p *Pager[T]) Next(ctx context.Context) bool {
+	if p.done || (p.maxResults > 0 && p.returned >= p.maxResults) {
+		p.done = true
+		return false
+	}
+
+	for len(p.buffered) == 0 {
+		items, err := p.fetch(ctx, p.page)
+		if err != nil {
+			p.err, p.done = err, true
+			return false
+		}
+		p.page++
+
+		for _, item := range items {
+			k := p.key(item)
+			if _, ok := p.seen[k]; ok {
+				continue
+			}
+			p.seen[k] = struct{}{}
+			p.buffered = append(p.buffered, item)
+		}
+
+		// An empty page ends the walk, and so does a page with nothing new, which would otherwise repeat forever
+		if len(p.buffered) == 0 {
+			p.done = true
+			return false
+		}
+	}
+
+	p.item, p.buffered = p.buffered[0], p.buffered[1:]
+	p.returned++
+
+	return true
+}
+
+// Item returns the result Next advanced to
+func (p *Pager[T]) Item() T {
+	return p.item
+}
+
+// Err returns the error that ended the walk, if any
+func (p *Pager[T]) Err() error {
+	return p.err
+}
+
+// Page returns the page the next fetch will request
+func (p *Pager[T]) Page() uint64 {
+	return p.page
+}
+
+func channelKey(c Channel) string {
+	return strconv.FormatInt(c.UserId, 10)
+}
+
+func videoKey(v Video) string {
+	return v.File
+}
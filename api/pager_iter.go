@@ -0,0 +1,48 @@
+//go:build go1.23
+
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"iter"
+)
+
+// All returns the remaining results as an iterator for range-over-func. The error that ends the walk, if any, is
+// yielded last with the zero result.
+//
+//	for channel, err := range client.SearchChannelsPager(query, 100).All(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(channel.Name)
+//	}
+func (p *Pager[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for p.Next(ctx) {
+			if !yield(p.Item(), nil) {
+				return
+			}
+		}
+
+		if err := p.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+//go:build go1.23
+
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPagerAll(t *testing.T) {
+	srv, _ := newSearchServer(t, http.StatusInternalServerError)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{}))
+
+	var names []string
+	var last error
+	for channel, err := range c.SearchChannelsPager(ChannelSearchQuery{Query: "art"}, 0).All(context.Background()) {
+		if err != nil {
+			last = err
+			break
+		}
+		names = append(names, channel.Name)
+	}
+
+	if want := []string{"one", "two", "three"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected results; expected: %v, got: %v", want, names)
+	}
+	if !errors.Is(last, ErrServerError) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrServerError, last)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// searchPages are the pages served by newSearchServer; channel 3 shifts from the first page onto the second
+var searchPages = map[string]string{
+	"1": `[{"user_id":1,"name":"one"},{"user_id":2,"name":"two"},{"user_id":3,"name":"three"}]`,
+	"2": `[{"user_id":3,"name":"three"},{"user_id":4,"name":"four"}]`,
+}
+
+// newSearchServer serves searchPages for /search/channels, an empty page after them, or failStatus for page 2 if set
+func newSearchServer(t *testing.T, failStatus int) (*httptest.Server, *int32) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		page := r.URL.Query().Get("page")
+		if page == "2" && failStatus != 0 {
+			w.Header().Set("x-ratelimit-remaining", "100")
+			w.WriteHeader(failStatus)
+			return
+		}
+
+		body, ok := searchPages[page]
+		if !ok {
+			body = "[]"
+		}
+		writeFixture(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+// collectChannels walks pager to the end and returns the names it produced
+func collectChannels(pager *Pager[Channel]) []string {
+	var names []string
+	for pager.Next(context.Background()) {
+		names = append(names, pager.Item().Name)
+	}
+
+	return names
+}
+
+func TestSearchChannelsPager(t *testing.T) {
+	srv, calls := newSearchServer(t, 0)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	pager := c.SearchChannelsPager(ChannelSearchQuery{Query: "art"}, 0)
+	names := collectChannels(pager)
+
+	if want := []string{"one", "two", "three", "four"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected results; expected: %v, got: %v", want, names)
+	}
+	if err := pager.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// Two pages of results and the empty page that ends the walk
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Errorf("unexpected request count; expected: 3, got: %d", n)
+	}
+	if pager.Next(context.Background()) {
+		t.Error("finished pager produced another result")
+	}
+}
+
+func TestSearchChannelsPagerMaxResults(t *testing.T) {
+	srv, calls := newSearchServer(t, 0)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	names := collectChannels(c.SearchChannelsPager(ChannelSearchQuery{Query: "art"}, 2))
+
+	if want := []string{"one", "two"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected results; expected: %v, got: %v", want, names)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("pages past the limit were fetched; request count: %d", n)
+	}
+}
+
+func TestSearchChannelsPagerError(t *testing.T) {
+	srv, _ := newSearchServer(t, http.StatusInternalServerError)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{}))
+
+	pager := c.SearchChannelsPager(ChannelSearchQuery{Query: "art"}, 0)
+	names := collectChannels(pager)
+
+	if want := []string{"one", "two", "three"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected results; expected: %v, got: %v", want, names)
+	}
+	if !errors.Is(pager.Err(), ErrServerError) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrServerError, pager.Err())
+	}
+	if pager.Page() != 2 {
+		t.Errorf("failed page was skipped; next page: %d", pager.Page())
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"math"
+)
+
+// Priority
+//
+// Decides the order in which waiting requests get rate-limit tokens. Set it per call with ContextWithPriority;
+// requests without one are PriorityNormal.
+type Priority int
+
+const (
+	PriorityBackground Priority = iota - 1
+	PriorityNormal
+	PriorityInteractive
+
+	priorityCount = 3
+)
+
+type priorityKey struct{}
+
+// ContextWithPriority returns a copy of ctx that makes requests run at priority p
+//
+//goland:noinspection GoUnusedExportedFunction
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityFrom returns the priority stored in ctx, clamped to the known priorities
+func priorityFrom(ctx context.Context) Priority {
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	if !ok {
+		return PriorityNormal
+	}
+
+	if p < PriorityBackground {
+		return PriorityBackground
+	}
+	if p > PriorityInteractive {
+		return PriorityInteractive
+	}
+
+	return p
+}
+
+// index maps a priority onto the per-priority arrays of the bucket
+func (p Priority) index() int {
+	return int(p - PriorityBackground)
+}
+
+// holdback returns how many tokens a request at priority p must leave in the bucket for higher priorities
+func (r *RateLimiter) holdback(p Priority, st *RateLimitState) int {
+	if st.Limit <= 0 {
+		return 0
+	}
+
+	// Never so much that p could not take a single token from a full budget
+	return int(math.Min(math.Ceil(r.reserved[p.index()]*float64(st.Limit)), float64(st.Limit-1)))
+}
+
+// canTake reports whether a request at priority p may take a token right now: enough budget must be left above its
+// holdback and no request of a higher priority may be waiting. The bucket must be locked by the caller.
+func (r *RateLimiter) canTake(p Priority, st *RateLimitState) bool {
+	if st.Remaining-r.holdback(p, st) < 1 {
+		return false
+	}
+
+	for q := p + 1; q <= PriorityInteractive; q++ {
+		if r.bucket.waiting[q.index()] > 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPriorityJumpsQueue(t *testing.T) {
+	clock := NewFakeClock(clockStart.Add(30 * time.Second))
+	r := newRateLimiter()
+	r.clock = clock
+	setState(r, RateLimitState{Remaining: 0, Reset: clockStart.Add(1 * time.Minute)})
+
+	bgCtx, bgCancel := context.WithCancel(ContextWithPriority(context.Background(), PriorityBackground))
+	defer bgCancel()
+
+	background := make(chan error, 1)
+	go func() {
+		_, err := r.reserve(bgCtx)
+		background <- err
+	}()
+
+	// The background request is queued first
+	clock.BlockUntil(1)
+
+	interactive := make(chan error, 1)
+	go func() {
+		_, err := r.reserve(ContextWithPriority(context.Background(), PriorityInteractive))
+		interactive <- err
+	}()
+	clock.BlockUntil(2)
+
+	// The refill only hands out a single probing token, which must go to the interactive request
+	clock.Advance(30 * time.Second)
+	if err := <-interactive; err != nil {
+		t.Fatalf("interactive request was not served: %v", err)
+	}
+
+	select {
+	case err := <-background:
+		t.Fatalf("background request should still be waiting; got: %v", err)
+	default:
+	}
+
+	bgCancel()
+	if err := <-background; !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error for the background request; got: %v", err)
+	}
+}
+
+func TestPriorityReserve(t *testing.T) {
+	c := NewClient("TEST_TOKEN", WithPriorityReserve(PriorityBackground, 0.25), WithClock(NewFakeClock(clockStart)))
+	r := c.limiter
+	setState(r, RateLimitState{Limit: 10, Remaining: 3, Reset: clockStart.Add(1 * time.Minute)})
+
+	ctx, cancel := context.WithTimeout(ContextWithPriority(context.Background(), PriorityBackground),
+		10*time.Millisecond)
+	defer cancel()
+
+	// 3 tokens of a 10 token limit are held back from background work
+	if _, err := r.reserve(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("background request should have been held back; got: %v", err)
+	}
+
+	if _, err := r.reserve(context.Background()); err != nil {
+		t.Errorf("normal request should have been served: %v", err)
+	}
+	if r.bucket.waiting != [priorityCount]int{} {
+		t.Errorf("waiters were not cleaned up: %v", r.bucket.waiting)
+	}
+}
+
+func TestPriorityReserveLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		p         Priority
+		fraction  float64
+		remaining int
+		served    bool
+	}{
+		{"negative fraction does not overspend", PriorityBackground, -0.5, 0, false},
+		{"full fraction still takes from a full budget", PriorityBackground, 1, 10, true},
+		{"full fraction leaves a spent budget alone", PriorityNormal, 1.5, 9, false},
+		{"interactive has no reserve", PriorityInteractive, 0.5, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("TEST_TOKEN", WithPriorityReserve(tt.p, tt.fraction), WithClock(NewFakeClock(clockStart)))
+			setState(c.limiter, RateLimitState{Limit: 10, Remaining: tt.remaining, Reset: clockStart.Add(1 * time.Minute)})
+
+			ctx, cancel := context.WithTimeout(ContextWithPriority(context.Background(), tt.p), 10*time.Millisecond)
+			defer cancel()
+
+			_, err := c.limiter.reserve(ctx)
+			if served := err == nil; served != tt.served {
+				t.Errorf("unexpected outcome; expected served: %t, got: %v", tt.served, err)
+			}
+		})
+	}
+}
+
+func TestPriorityFrom(t *testing.T) {
+	tests := map[Priority]Priority{
+		PriorityBackground:  PriorityBackground,
+		PriorityNormal:      PriorityNormal,
+		PriorityInteractive: PriorityInteractive,
+		Priority(-10):       PriorityBackground,
+		Priority(10):        PriorityInteractive,
+	}
+
+	for in, want := range tests {
+		if got := priorityFrom(ContextWithPriority(context.Background(), in)); got != want {
+			t.Errorf("priorityFrom(%d); expected: %d, got: %d", in, want, got)
+		}
+	}
+
+	if got := priorityFrom(context.Background()); got != PriorityNormal {
+		t.Errorf("unexpected default priority; got: %d", got)
+	}
+}
@@ -17,14 +17,11 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
-	"strings"
-
-	log "github.com/veteran-software/nowlive-logging"
 )
 
 const (
@@ -38,346 +35,413 @@ const (
 
 // GetCategories
 //
-// Get information about all categories
+// Get information about all categories.
+//
+// Scopes: none.
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetCategories() *[]Category {
-	resp, err := Rest.Request(http.MethodGet, api+"/categories", nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+func (c *Client) GetCategories() ([]Category, error) {
+	return c.GetCategoriesContext(context.Background())
+}
+
+// GetCategoriesContext
+//
+// Same as GetCategories, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetCategoriesContext(ctx context.Context) ([]Category, error) {
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+"/categories", nil)
+	// A stale response comes with a *StaleError, which is returned along with the decoded result
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var categories []Category
-	err = json.NewDecoder(resp.Body).Decode(&categories)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&categories); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &categories
+	return categories, err
 }
 
 // GetChannelByID
 //
-// Gets information about a channel by ID - providing a bearer token with permission readpub can get followed status in result
+// Gets information about a channel by ID.
+//
+// Scopes: none; oauth.ScopeReadPub when called for a user, who then gets Following filled in.
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetChannelByID(channelID int) *Channel {
-	resp, err := Rest.Request(http.MethodGet, api+fmt.Sprintf("/channel/id/%d", channelID), nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+func (c *Client) GetChannelByID(channelID int) (*Channel, error) {
+	return c.GetChannelByIDContext(context.Background(), channelID)
+}
+
+// GetChannelByIDContext
+//
+// Same as GetChannelByID, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetChannelByIDContext(ctx context.Context, channelID int) (*Channel, error) {
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+fmt.Sprintf("/channel/id/%d", channelID), nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var channel Channel
-	err = json.NewDecoder(resp.Body).Decode(&channel)
-	if err != nil {
-		fmt.Println(err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&channel); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &channel
+	return &channel, err
 }
 
 // GetChannelByName
 //
-// Gets information about a channel by name - providing a bearer token with permission readpub can get followed status in result
+// Gets information about a channel by name.
+//
+// Scopes: none; oauth.ScopeReadPub when called for a user, who then gets Following filled in.
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetChannelByName(channelName string) (*Channel, error) {
+	return c.GetChannelByNameContext(context.Background(), channelName)
+}
+
+// GetChannelByNameContext
+//
+// Same as GetChannelByName, but the request and any rate-limit wait are bound to ctx
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetChannelByName(channelName string) *Channel {
-	resp, err := Rest.Request(http.MethodGet, api+fmt.Sprintf("/channel/name/%s", channelName), nil)
+func (c *Client) GetChannelByNameContext(ctx context.Context, channelName string) (*Channel, error) {
+	route, err := c.channelNameRoute(channelName, "")
 	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+		return nil, err
+	}
+
+	resp, err := c.RequestContext(ctx, http.MethodGet, route, nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var channel Channel
-	err = json.NewDecoder(resp.Body).Decode(&channel)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&channel); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &channel
+	return &channel, err
 }
 
 // GetAllChannelVideosByChannelID
 //
-// Get all videos for a channel by id
+// Get all videos for a channel by id.
+//
+// Scopes: none.
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetAllChannelVideosByChannelID(channelID int) *[]Video {
-	resp, err := Rest.Request(http.MethodGet, api+fmt.Sprintf("/channel/id/%d/videos", channelID), nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+func (c *Client) GetAllChannelVideosByChannelID(channelID int) ([]Video, error) {
+	return c.GetAllChannelVideosByChannelIDContext(context.Background(), channelID)
+}
+
+// GetAllChannelVideosByChannelIDContext
+//
+// Same as GetAllChannelVideosByChannelID, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetAllChannelVideosByChannelIDContext(ctx context.Context, channelID int) ([]Video, error) {
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+fmt.Sprintf("/channel/id/%d/videos", channelID), nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var videos []Video
-	err = json.NewDecoder(resp.Body).Decode(&videos)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&videos); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &videos
+	return videos, err
 }
 
 // GetAllChannelVideosByChannelName
 //
-// Get all videos for a channel by name
+// Get all videos for a channel by name.
+//
+// Scopes: none.
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetAllChannelVideosByChannelName(channelName string) *[]Video {
-	resp, err := Rest.Request(http.MethodGet, api+fmt.Sprintf("/channel/name/%s/videos", channelName), nil)
+func (c *Client) GetAllChannelVideosByChannelName(channelName string) ([]Video, error) {
+	return c.GetAllChannelVideosByChannelNameContext(context.Background(), channelName)
+}
+
+// GetAllChannelVideosByChannelNameContext
+//
+// Same as GetAllChannelVideosByChannelName, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetAllChannelVideosByChannelNameContext(ctx context.Context, channelName string) ([]Video, error) {
+	route, err := c.channelNameRoute(channelName, "/videos")
 	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+		return nil, err
+	}
+
+	resp, err := c.RequestContext(ctx, http.MethodGet, route, nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var videos []Video
-	err = json.NewDecoder(resp.Body).Decode(&videos)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&videos); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &videos
+	return videos, err
 }
 
 // GetOnline
 //
-// Gets all currently online channels - providing a bearer token with permission readpub can get followed status in result
+// Gets all currently online channels.
+//
+// Scopes: none; oauth.ScopeReadPub when called for a user, who then gets Following filled in.
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetOnline(adult *bool, gaming *bool, category ...string) *[]Online {
-	var qsp []string
-	if adult == nil {
-		*adult = false
-	}
-	if gaming == nil {
-		*gaming = false
-	}
-
-	qsp = append(qsp, Adult+strconv.FormatBool(*adult), "gaming="+strconv.FormatBool(*gaming))
-
-	if category != nil && len(category) > 0 {
-		qsp = append(qsp, "category="+strings.Join(category, ","))
-	}
-
-	var params string
-	if len(qsp) > 0 {
-		params = "?" + strings.Join(qsp, "&")
-	}
+func (c *Client) GetOnline(query OnlineQuery) ([]Online, error) {
+	return c.GetOnlineContext(context.Background(), query)
+}
 
-	resp, err := Rest.Request(http.MethodGet, fmt.Sprintf(api+"/online%s", params), nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+// GetOnlineContext
+//
+// Same as GetOnline, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetOnlineContext(ctx context.Context, query OnlineQuery) ([]Online, error) {
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+"/online?"+query.Values().Encode(), nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var online []Online
-	err = json.NewDecoder(resp.Body).Decode(&online)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&online); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &online
+	return online, err
 }
 
 // SearchChannels
 //
-// Get all channels matching the given search criteria (by name and tags)
+// Get all channels matching the given search criteria (by name and tags).
+//
+// Scopes: none.
 //
 //goland:noinspection GoUnusedExportedFunction
-func SearchChannels(q string, adult *bool, page *uint64, commissions *bool) *[]Channel {
-	var qsp []string
-
-	if q == "" {
-		return nil
-	}
-
-	qsp = append(qsp, q)
-
-	if adult == nil {
-		*adult = false
-	}
-	if page == nil {
-		*page = 1
-	}
-	if commissions == nil {
-		*commissions = false
-	}
-
-	qsp = append(qsp,
-		Adult+strconv.FormatBool(*adult),
-		"page="+strconv.FormatUint(*page, 10),
-		"commissions="+strconv.FormatBool(*commissions))
+func (c *Client) SearchChannels(query ChannelSearchQuery) ([]Channel, error) {
+	return c.SearchChannelsContext(context.Background(), query)
+}
 
-	var params string
-	if len(qsp) > 0 {
-		params = "?" + strings.Join(qsp, "&")
+// SearchChannelsContext
+//
+// Same as SearchChannels, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) SearchChannelsContext(ctx context.Context, query ChannelSearchQuery) ([]Channel, error) {
+	if query.Query == "" {
+		return nil, ErrEmptyQuery
 	}
 
-	resp, err := Rest.Request(http.MethodGet, fmt.Sprintf(api+"/search/channels%s", params), nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+"/search/channels?"+query.Values().Encode(), nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var channels []Channel
-	err = json.NewDecoder(resp.Body).Decode(&channels)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&channels); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &channels
+	return channels, err
 }
 
 // SearchVideos
 //
-// Get all channels matching the given search criteria (by name and tags)
+// Get all videos matching the given search criteria.
+//
+// Scopes: none.
 //
 //goland:noinspection GoUnusedExportedFunction
-func SearchVideos(q string, adult *bool, page *uint64) *[]Video {
-	var qsp []string
-
-	if q == "" {
-		return nil
-	}
-
-	qsp = append(qsp, q)
+func (c *Client) SearchVideos(query VideoSearchQuery) ([]Video, error) {
+	return c.SearchVideosContext(context.Background(), query)
+}
 
-	if adult == nil {
-		*adult = false
-	}
-	if page == nil {
-		*page = 1
+// SearchVideosContext
+//
+// Same as SearchVideos, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) SearchVideosContext(ctx context.Context, query VideoSearchQuery) ([]Video, error) {
+	if query.Query == "" {
+		return nil, ErrEmptyQuery
 	}
 
-	qsp = append(qsp,
-		Adult+strconv.FormatBool(*adult),
-		"page="+strconv.FormatUint(*page, 10))
-
-	var params string
-	if len(qsp) > 0 {
-		params = "?" + strings.Join(qsp, "&")
-	}
-
-	resp, err := Rest.Request(http.MethodGet, fmt.Sprintf(api+"/search/videos%s", params), nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+"/search/videos?"+query.Values().Encode(), nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var videos []Video
-	err = json.NewDecoder(resp.Body).Decode(&videos)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&videos); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &videos
+	return videos, err
+}
+
+// SearchChannelsPager
+//
+// Walks every page of channels matching query, starting at query.Page, returning at most maxResults channels (0 for no
+// limit)
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) SearchChannelsPager(query ChannelSearchQuery, maxResults int) *Pager[Channel] {
+	return newPager(query.Page, maxResults, channelKey, func(ctx context.Context, page uint64) ([]Channel, error) {
+		query.Page = page
+		return c.SearchChannelsContext(ctx, query)
+	})
+}
+
+// SearchVideosPager
+//
+// Walks every page of videos matching query, starting at query.Page, returning at most maxResults videos (0 for no
+// limit)
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) SearchVideosPager(query VideoSearchQuery, maxResults int) *Pager[Video] {
+	return newPager(query.Page, maxResults, videoKey, func(ctx context.Context, page uint64) ([]Video, error) {
+		query.Page = page
+		return c.SearchVideosContext(ctx, query)
+	})
 }
 
 // GetStreamByChannelID
 //
-// Get stream
+// Get stream.
+//
+// Scopes: none.
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetStreamByChannelID(channelID int) *Stream {
-	resp, err := Rest.Request(http.MethodGet, api+fmt.Sprintf("/channel/id/%d/streams", channelID), nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+func (c *Client) GetStreamByChannelID(channelID int) (*Stream, error) {
+	return c.GetStreamByChannelIDContext(context.Background(), channelID)
+}
+
+// GetStreamByChannelIDContext
+//
+// Same as GetStreamByChannelID, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetStreamByChannelIDContext(ctx context.Context, channelID int) (*Stream, error) {
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+fmt.Sprintf("/channel/id/%d/streams", channelID), nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var stream Stream
-	err = json.NewDecoder(resp.Body).Decode(&stream)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&stream); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &stream
+	return &stream, err
 }
 
 // GetStreamByChannelName
 //
-// Get stream
+// Get stream.
+//
+// Scopes: none.
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetStreamByChannelName(channelName string) (*Stream, error) {
+	return c.GetStreamByChannelNameContext(context.Background(), channelName)
+}
+
+// GetStreamByChannelNameContext
+//
+// Same as GetStreamByChannelName, but the request and any rate-limit wait are bound to ctx
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetStreamByChannelName(channelName string) *Stream {
-	resp, err := Rest.Request(http.MethodGet, api+fmt.Sprintf("/channel/name/%s/streams", channelName), nil)
+func (c *Client) GetStreamByChannelNameContext(ctx context.Context, channelName string) (*Stream, error) {
+	route, err := c.channelNameRoute(channelName, "/streams")
 	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+		return nil, err
+	}
+
+	resp, err := c.RequestContext(ctx, http.MethodGet, route, nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var stream Stream
-	err = json.NewDecoder(resp.Body).Decode(&stream)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&stream); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &stream
+	return &stream, err
 }
 
 // GetNotifications
 //
-// Get all global notifications/announcements
+// Get all global notifications/announcements.
+//
+// Scopes: none.
 //
 //goland:noinspection GoUnusedExportedFunction
-func GetNotifications() *Notification {
-	resp, err := Rest.Request(http.MethodGet, api+"/notifications", nil)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+func (c *Client) GetNotifications() (*Notification, error) {
+	return c.GetNotificationsContext(context.Background())
+}
+
+// GetNotificationsContext
+//
+// Same as GetNotifications, but the request and any rate-limit wait are bound to ctx
+//
+//goland:noinspection GoUnusedExportedFunction
+func (c *Client) GetNotificationsContext(ctx context.Context) (*Notification, error) {
+	resp, err := c.RequestContext(ctx, http.MethodGet, c.baseURL+"/notifications", nil)
+	if resp == nil {
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
 
 	var notification Notification
-	err = json.NewDecoder(resp.Body).Decode(&notification)
-	if err != nil {
-		log.Errorln(log.Picarto, log.FuncName(), err)
-		return nil
+	if decodeErr := json.NewDecoder(resp.Body).Decode(&notification); decodeErr != nil {
+		return nil, decodeError(resp, decodeErr)
 	}
 
-	return &notification
+	return &notification, err
 }
@@ -17,33 +17,77 @@
 package api
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 )
 
 const (
-	nilError         = "an error occurred and nil was returned"
 	returnTypeBool   = "unexpected return type; expected: bool, got: %T"
 	returnTypeInt    = "unexpected return type; expected: int, got: %T"
 	returnTypeString = "unexpected return type; expected: string, got: %T"
 )
 
-func TestGetCategories(t *testing.T) {
-	Rest = NewPicarto("TEST_TOKEN")
+const (
+	categoriesFixture = `[{"id":1,"name":"Creative","adult":false,"is_active":true,"image":"creative.jpg",
+"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:00Z","deleted_at":null,"total_viewers":120,
+"total_channels":5000,"online_channels":80,"total_views":"123456"}]`
+
+	channelFixture = `{"user_id":527732,"name":"AgueMort","avatar":"https://picarto.tv/avatar.jpg","online":true,
+"viewers":12,"viewers_total":3400,"thumbnails":{"web":"web.jpg","web_large":"web_large.jpg","mobile":"mobile.jpg",
+"tablet":"tablet.jpg"},"followers":250,"subscribers":3,"adult":false,"category":["Creative"],"account_type":"free",
+"commissions":true,"recordings":false,"title":"Drawing","description_panels":[],"private":false,"private_message":"",
+"gaming":false,"chat_settings":{"guest_chat":true,"links":true,"level":0},"last_live":null,"tags":["art"],
+"multistream":[],"languages":[{"id":1,"name":"English"}],"following":false,"creation_date":"2018-05-01"}`
+)
 
-	categories := GetCategories()
+// newTestServer serves canned Picarto responses so the wrapper can be exercised without touching the real API
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/categories", func(w http.ResponseWriter, r *http.Request) {
+		writeFixture(w, categoriesFixture)
+	})
+	mux.HandleFunc("/channel/id/527732", func(w http.ResponseWriter, r *http.Request) {
+		writeFixture(w, channelFixture)
+	})
+	mux.HandleFunc("/channel/name/AgueMort", func(w http.ResponseWriter, r *http.Request) {
+		writeFixture(w, channelFixture)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
 
-	if categories == nil {
-		t.Error(nilError)
+func writeFixture(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-ratelimit-limit", "150")
+	w.Header().Set("x-ratelimit-remaining", "149")
+	_, _ = w.Write([]byte(body))
+}
+
+func newTestClient(t *testing.T) *Client {
+	return NewClient("TEST_TOKEN", WithBaseURL(newTestServer(t).URL))
+}
+
+func TestGetCategories(t *testing.T) {
+	Rest = newTestClient(t)
+
+	categories, err := GetCategories()
+	if err != nil {
+		t.Error(err)
 		return
 	}
 
-	if len(*categories) == 0 {
+	if len(categories) == 0 {
 		t.Error("empty category list returned")
 		return
 	}
 
-	for _, cat := range *categories {
+	for _, cat := range categories {
 		if reflect.ValueOf(cat.ID).Kind() != reflect.Int {
 			t.Errorf(returnTypeInt, reflect.ValueOf(cat.ID).Kind())
 		}
@@ -66,12 +110,11 @@ func TestGetCategories(t *testing.T) {
 }
 
 func TestGetChannelByID(t *testing.T) {
-	Rest = NewPicarto("TEST_TOKEN")
-
-	c := GetChannelByID(527732)
+	Rest = newTestClient(t)
 
-	if c == nil {
-		t.Error(nilError)
+	c, err := GetChannelByID(527732)
+	if err != nil {
+		t.Error(err)
 		return
 	}
 
@@ -79,18 +122,75 @@ func TestGetChannelByID(t *testing.T) {
 }
 
 func TestGetChannelByName(t *testing.T) {
-	Rest = NewPicarto("TEST_TOKEN")
+	Rest = newTestClient(t)
 
-	c := GetChannelByName("AgueMort")
-
-	if c == nil {
-		t.Error(nilError)
+	c, err := GetChannelByName("AgueMort")
+	if err != nil {
+		t.Error(err)
 		return
 	}
 
 	evalChannelFields(t, c)
 }
 
+func TestGetChannelByNameNotFound(t *testing.T) {
+	Rest = newTestClient(t)
+
+	c, err := GetChannelByName("nobody")
+	if c != nil {
+		t.Errorf("expected a nil channel, got: %+v", c)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrNotFound, err)
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		writeFixture(w, categoriesFixture)
+	}))
+	defer srv.Close()
+
+	c := NewClient("CLIENT_ID", WithClientSecret("SECRET"), WithBaseURL(srv.URL+"/"), WithHTTPClient(srv.Client()))
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Get("Client-ID") != "CLIENT_ID" {
+		t.Errorf("unexpected Client-ID; got: %q", got.Get("Client-ID"))
+	}
+	if got.Get("Authorization") != "Bearer SECRET" {
+		t.Errorf("unexpected Authorization; got: %q", got.Get("Authorization"))
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestWithTransport(t *testing.T) {
+	srv := newTestServer(t)
+
+	var calls int
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithTransport(transport))
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Errorf("unexpected number of transport calls; expected: 1, got: %d", calls)
+	}
+}
+
 func evalChannelFields(t *testing.T, c *Channel) {
 	/* Type check all params */
 	if reflect.ValueOf(c.UserId).Kind() != reflect.Int64 {
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OnlineQuery
+//
+// Filters for GetOnline. The zero value lists every online channel that is neither adult nor gaming.
+type OnlineQuery struct {
+	// Adult includes adult channels
+	Adult bool
+	// Gaming includes gaming channels
+	Gaming bool
+	// Categories limits the list to channels streaming in any of the named categories
+	Categories []string
+}
+
+// Values encodes the query string parameters of the query
+func (q OnlineQuery) Values() url.Values {
+	v := url.Values{}
+	v.Set("adult", strconv.FormatBool(q.Adult))
+	v.Set("gaming", strconv.FormatBool(q.Gaming))
+
+	if categories := joinNonEmpty(q.Categories); categories != "" {
+		v.Set("categories", categories)
+	}
+
+	return v
+}
+
+// ChannelSearchQuery
+//
+// The search terms and filters for SearchChannels. Query is required; page 0 is the same as page 1.
+type ChannelSearchQuery struct {
+	// Query is matched against channel names and tags
+	Query string
+	// Adult includes adult channels
+	Adult bool
+	// Commissions only returns channels that are open for commissions
+	Commissions bool
+	// Page is the 1-based page of results
+	Page uint64
+}
+
+// Values encodes the query string parameters of the query
+func (q ChannelSearchQuery) Values() url.Values {
+	v := url.Values{}
+	v.Set("q", q.Query)
+	v.Set("adult", strconv.FormatBool(q.Adult))
+	v.Set("commissions", strconv.FormatBool(q.Commissions))
+	v.Set("page", strconv.FormatUint(pageOrFirst(q.Page), 10))
+
+	return v
+}
+
+// VideoSearchQuery
+//
+// The search terms and filters for SearchVideos. Query is required; page 0 is the same as page 1.
+type VideoSearchQuery struct {
+	// Query is matched against video titles and their channels
+	Query string
+	// Adult includes adult videos
+	Adult bool
+	// Page is the 1-based page of results
+	Page uint64
+}
+
+// Values encodes the query string parameters of the query
+func (q VideoSearchQuery) Values() url.Values {
+	v := url.Values{}
+	v.Set("q", q.Query)
+	v.Set("adult", strconv.FormatBool(q.Adult))
+	v.Set("page", strconv.FormatUint(pageOrFirst(q.Page), 10))
+
+	return v
+}
+
+// pageOrFirst maps the unset page 0 to the first page
+func pageOrFirst(page uint64) uint64 {
+	if page == 0 {
+		return 1
+	}
+
+	return page
+}
+
+// joinNonEmpty joins the non-blank values with commas
+func joinNonEmpty(values []string) string {
+	var kept []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			kept = append(kept, v)
+		}
+	}
+
+	return strings.Join(kept, ",")
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestQueryValues(t *testing.T) {
+	tests := map[string]interface{ Values() url.Values }{
+		"adult=false&gaming=false": OnlineQuery{},
+		"adult=true&categories=Art%2CGame+Development&gaming=true": OnlineQuery{
+			Adult:      true,
+			Gaming:     true,
+			Categories: []string{"Art", " ", "Game Development"},
+		},
+		"adult=false&commissions=false&page=1&q=pixel+art": ChannelSearchQuery{Query: "pixel art"},
+		"adult=true&commissions=true&page=3&q=a%26b%3Dc%23d": ChannelSearchQuery{
+			Query:       "a&b=c#d",
+			Adult:       true,
+			Commissions: true,
+			Page:        3,
+		},
+		"adult=false&page=1&q=speedpaint": VideoSearchQuery{Query: "speedpaint"},
+		"adult=true&page=2&q=%3F":         VideoSearchQuery{Query: "?", Adult: true, Page: 2},
+	}
+
+	for want, query := range tests {
+		if got := query.Values().Encode(); got != want {
+			t.Errorf("%T; expected: %s, got: %s", query, want, got)
+		}
+	}
+}
+
+func TestSearchSendsQuery(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Path + "?" + r.URL.RawQuery
+		writeFixture(w, "[]")
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	if _, err := c.SearchChannels(ChannelSearchQuery{Query: "AgueMort", Page: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/search/channels?adult=false&commissions=false&page=2&q=AgueMort"; got != want {
+		t.Errorf("unexpected request; expected: %s, got: %s", want, got)
+	}
+
+	if _, err := c.GetOnline(OnlineQuery{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/online?adult=false&gaming=false"; got != want {
+		t.Errorf("unexpected request; expected: %s, got: %s", want, got)
+	}
+
+	if _, err := c.SearchVideos(VideoSearchQuery{}); !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrEmptyQuery, err)
+	}
+}
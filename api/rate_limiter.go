@@ -17,81 +17,354 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"sync"
 	"time"
 )
 
-var Rest *RateLimiter
-
+// RateLimiter
+//
+// Keeps track of Picarto's per-minute request budget. A token is reserved from the bucket before each request is sent
+// and the bucket is reconciled with the x-ratelimit-remaining header once the response arrives, so any number of
+// requests may be in flight as long as budget is left. The budget itself lives in a RateLimitStore, which may be shared
+// with other clients and processes.
 type RateLimiter struct {
-	sync.Mutex
-
 	bucket *bucket
+	store  RateLimitStore
+	clock  Clock
+
+	// observer, when set, is called outside the bucket lock on every state change and every delayed request
+	observer func(RateLimitEvent)
+
+	// reserved is the fraction of the limit each priority leaves untouched for higher priorities
+	reserved [priorityCount]float64
 }
 
+// RateLimit
+//
+// A point-in-time snapshot of the rate-limit budget
+type RateLimit struct {
+	// Limit is the per-minute budget reported by Picarto; 0 until the first response carrying x-ratelimit-limit
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	// InFlight is the number of this client's requests that have reserved a token but whose response has not arrived
+	InFlight int
+	// ClockSkew is the estimated offset of Picarto's clock from the local clock; positive when Picarto is ahead
+	ClockSkew time.Duration
+}
+
+// RateLimitEvent
+//
+// Passed to the observer set with WithRateLimitObserver
+type RateLimitEvent struct {
+	RateLimit
+
+	// Delay is how long a request is about to be held back by the limiter; 0 for plain state changes
+	Delay time.Duration
+}
+
+// bucket holds the process-local side of the limiter; the budget itself is kept in the store
 type bucket struct {
 	sync.Mutex
 
-	Remaining int
-	limit     int
-	reset     time.Time
 	lastReset time.Time
-}
 
-func NewPicarto(clientId string, clientSecret ...string) *RateLimiter {
-	token = clientId
-	if clientSecret != nil && len(clientSecret) == 1 {
-		secret = &clientSecret[0]
-	}
+	// inFlight counts reserved tokens whose response has not been reconciled yet
+	inFlight int
+
+	// skew is the running estimate of Picarto's clock minus the local clock, from the Date header of each response
+	skew        time.Duration
+	skewSamples int
+
+	// waiting counts the requests blocked in reserve, per priority
+	waiting [priorityCount]int
+	// wake is closed and replaced whenever waiting requests should check the bucket again
+	wake chan struct{}
+}
 
+func newRateLimiter() *RateLimiter {
 	return &RateLimiter{
 		bucket: &bucket{
-			Remaining: 1,
-			reset:     time.Now().UTC().Truncate(60 * time.Second).Add(1 * time.Minute),
+			wake: make(chan struct{}),
 		},
+		store: NewMemoryRateLimitStore(),
+		clock: realClock{},
 	}
 }
 
-func (r *RateLimiter) getWaitTime(minRemaining int) time.Duration {
-	if r.bucket.Remaining < minRemaining && r.bucket.reset.After(time.Now()) {
-		return r.bucket.reset.Sub(time.Now())
+// State returns a snapshot of the current rate-limit budget
+func (r *RateLimiter) State() RateLimit {
+	r.bucket.Lock()
+	defer r.bucket.Unlock()
+
+	var state RateLimit
+	_ = r.store.Update(func(st *RateLimitState) error {
+		state = r.bucket.snapshot(st)
+		return nil
+	})
+
+	return state
+}
+
+// broadcast wakes every request waiting in reserve; the bucket must be locked by the caller
+func (b *bucket) broadcast() {
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+// notify passes state to the observer, if any; it must be called without holding the bucket lock
+func (r *RateLimiter) notify(state RateLimit, delay time.Duration) {
+	if r.observer != nil {
+		r.observer(RateLimitEvent{RateLimit: state, Delay: delay})
 	}
+}
 
-	return 0
+// snapshot must be called with the bucket locked
+func (b *bucket) snapshot(st *RateLimitState) RateLimit {
+	remaining := st.Remaining
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return RateLimit{
+		Limit:     st.Limit,
+		Remaining: remaining,
+		Reset:     st.Reset,
+		InFlight:  b.inFlight,
+		ClockSkew: b.skew,
+	}
 }
 
-func (r *RateLimiter) lockBucketObject() {
-	r.bucket.Lock()
+// endProbe hands out another probing token once a probe ended without telling us the budget, e.g. with a transport
+// error or a gateway error page, so that it can be retried without waiting for the next window. The bucket must be
+// locked by the caller.
+func (b *bucket) endProbe(st *RateLimitState) {
+	if st.Estimated && st.Remaining <= 0 && b.inFlight == 0 {
+		st.Remaining = 1
+	}
+}
 
-	if wait := r.getWaitTime(1); wait > 0 {
-		time.Sleep(wait)
+// nextMinute returns the top of the minute following t, which is when Picarto resets its budget
+func nextMinute(t time.Time) time.Time {
+	return t.UTC().Truncate(1 * time.Minute).Add(1 * time.Minute)
+}
+
+// skewSmoothing is how strongly the skew estimate resists a single new sample; each sample moves it by 1/skewSmoothing
+const skewSmoothing = 8
+
+// observeSkew folds a new sample of Picarto's clock minus the local clock into the running estimate.
+// The bucket must be locked by the caller.
+func (b *bucket) observeSkew(sample time.Duration) {
+	if b.skewSamples == 0 {
+		b.skew = sample
+	} else {
+		b.skew += (sample - b.skew) / skewSmoothing
 	}
+	b.skewSamples++
+}
 
-	r.bucket.Remaining--
+// nextServerMinute returns, in local time, the next top of the minute on Picarto's clock.
+// The bucket must be locked by the caller.
+func (b *bucket) nextServerMinute(now time.Time) time.Time {
+	return nextMinute(now.Add(b.skew)).Add(-b.skew)
 }
 
-func (r *RateLimiter) lockBucket() {
-	r.lockBucketObject()
+// refill starts a new window once the reset time has passed and reports whether it did. Until the first response of
+// the new window tells us the real budget, only the known limit is handed out, or a single probing request when the
+// limit is unknown or the window ended with a 429. Whichever client sharing the store notices the reset first performs
+// the refill.
+//
+// The bucket must be locked by the caller.
+func (b *bucket) refill(now time.Time, st *RateLimitState) bool {
+	if now.Before(st.Reset) {
+		return false
+	}
+
+	throttled := !st.Throttled.IsZero()
+
+	b.lastReset = st.Reset
+	st.Reset = b.nextServerMinute(now)
+	st.Estimated = true
+	st.Throttled = time.Time{}
+
+	// A Retry-After deadline may end in the middle of Picarto's window, when most of its budget may still be spent
+	if st.Limit > 0 && !throttled {
+		st.Remaining = st.Limit - b.inFlight
+	} else {
+		st.Remaining = 1
+	}
+
+	return true
 }
 
-func (b *bucket) release(headers http.Header) error {
-	defer b.Unlock()
+// reserve takes a token from the bucket for a request at the priority stored in ctx and reports how long it had to
+// wait. Requests of a higher priority are served first. Every successful reserve must be followed by exactly one
+// release.
+func (r *RateLimiter) reserve(ctx context.Context) (time.Duration, error) {
+	p := priorityFrom(ctx)
+	start := r.clock.Now()
+	queued := false
+
+	for {
+		r.bucket.Lock()
+
+		now := r.clock.Now()
+
+		var took, refilled bool
+		var wait time.Duration
+		var state RateLimit
+		err := r.store.Update(func(st *RateLimitState) error {
+			refilled = r.bucket.refill(now, st)
+
+			if took = r.canTake(p, st); took {
+				st.Remaining--
+			}
+
+			wait = st.Reset.Sub(now)
+			state = r.bucket.snapshot(st)
+
+			return nil
+		})
+		if err != nil {
+			if queued {
+				r.bucket.waiting[p.index()]--
+				r.bucket.broadcast()
+			}
+			r.bucket.Unlock()
+
+			return r.clock.Now().Sub(start), err
+		}
 
+		if refilled {
+			r.bucket.broadcast()
+		}
+
+		if took {
+			r.bucket.inFlight++
+			state.InFlight = r.bucket.inFlight
+			if queued {
+				r.bucket.waiting[p.index()]--
+				// Lower priorities may have been held back only by this request
+				r.bucket.broadcast()
+			}
+			r.bucket.Unlock()
+
+			r.notify(state, 0)
+
+			if !queued {
+				return 0, nil
+			}
+			return r.clock.Now().Sub(start), nil
+		}
+
+		if !queued {
+			r.bucket.waiting[p.index()]++
+			queued = true
+		}
+
+		wake := r.bucket.wake
+		r.bucket.Unlock()
+
+		r.notify(state, wait)
+
+		timer := r.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			r.bucket.Lock()
+			r.bucket.waiting[p.index()]--
+			r.bucket.broadcast()
+			r.bucket.Unlock()
+
+			return r.clock.Now().Sub(start), ctx.Err()
+		case <-wake:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+}
+
+// exhaust empties the bucket until the given time, e.g. after Picarto answered 429
+func (r *RateLimiter) exhaust(until time.Time) {
+	r.bucket.Lock()
+
+	var state RateLimit
+	_ = r.store.Update(func(st *RateLimitState) error {
+		if st.Throttled.IsZero() {
+			st.Throttled = st.Reset
+			if now := r.clock.Now(); !now.Before(st.Throttled) {
+				st.Throttled = r.bucket.nextServerMinute(now)
+			}
+		}
+
+		st.Remaining = 0
+		st.Estimated = false
+		st.Reset = until
+		state = r.bucket.snapshot(st)
+
+		return nil
+	})
+
+	r.bucket.broadcast()
+	r.bucket.Unlock()
+
+	r.notify(state, 0)
+}
+
+// release returns the reservation made by reserve and reconciles the bucket with the response headers, if any
+func (r *RateLimiter) release(headers http.Header) error {
+	r.bucket.Lock()
+
+	r.bucket.inFlight--
+
+	var state RateLimit
+	err := r.store.Update(func(st *RateLimitState) error {
+		err := r.bucket.release(headers, st, r.clock.Now())
+		state = r.bucket.snapshot(st)
+
+		return err
+	})
+
+	r.bucket.broadcast()
+	r.bucket.Unlock()
+
+	r.notify(state, 0)
+
+	return err
+}
+
+// resetTime returns when the current window ends
+func (r *RateLimiter) resetTime() time.Time {
+	return r.State().Reset
+}
+
+// release reconciles the budget with the response headers received at now; the bucket must be locked by the caller
+func (b *bucket) release(headers http.Header, st *RateLimitState, now time.Time) error {
+	//goland:noinspection SpellCheckingInspection
+	if headers == nil || headers.Get("x-ratelimit-remaining") == "" {
+		b.endProbe(st)
+	}
 	if headers == nil {
 		return nil
 	}
 
 	//goland:noinspection SpellCheckingInspection
 	remaining := headers.Get("x-ratelimit-remaining")
+	//goland:noinspection SpellCheckingInspection
+	limit := headers.Get("x-ratelimit-limit")
 
 	// Picarto does not expose a specific reset time in the headers since they reset at the top of each minute
 	// So we're going to parse the Date header and do it ourselves
 	serverDate := headers.Get("Date")
 
-	if serverDate != "" {
-		parsedDate, _ := time.Parse(time.RFC1123, serverDate)
+	newWindow := false
+	if parsedDate, err := time.Parse(time.RFC1123, serverDate); serverDate != "" && err == nil {
+		// The header only has whole seconds, so the middle of that second is the best guess of the server's time
+		b.observeSkew(parsedDate.Add(500 * time.Millisecond).Sub(now))
 
 		// This prevents accidental rounding up an extra minute
 		var d time.Duration
@@ -100,7 +373,37 @@ func (b *bucket) release(headers http.Header) error {
 		} else {
 			d = 1 * time.Minute
 		}
-		b.reset = parsedDate.Add(d).Round(1 * time.Minute)
+		// The window is told apart on the server's clock, where minutes are exact, and only then converted to the
+		// local clock with the skew estimate, which wobbles a little with every response
+		serverReset := parsedDate.Add(d).Round(1 * time.Minute)
+		reset := serverReset.Add(-b.skew)
+		current := st.Reset.Add(b.skew).Round(1 * time.Minute)
+
+		switch {
+		case !st.Throttled.IsZero() && reset.Sub(st.Throttled) < 30*time.Second:
+			// Still the window that was throttled; the Retry-After deadline stands
+		case !st.Throttled.IsZero():
+			st.Throttled = time.Time{}
+			b.lastReset = st.Reset
+			newWindow = true
+			st.Reset = reset
+		case serverReset.After(current):
+			b.lastReset = st.Reset
+			newWindow = true
+			st.Reset = reset
+		case !serverReset.Before(current):
+			// A late response from an earlier window must not move the reset back
+			st.Reset = reset
+		}
+	}
+
+	if limit != "" {
+		parsedLimit, err := strconv.ParseInt(limit, 10, 32)
+		if err != nil {
+			return err
+		}
+
+		st.Limit = int(parsedLimit)
 	}
 
 	if remaining != "" {
@@ -109,7 +412,16 @@ func (b *bucket) release(headers http.Header) error {
 			return err
 		}
 
-		b.Remaining = int(parsedRemaining)
+		// Requests still in flight may not have been counted by Picarto yet, so keep their tokens reserved.
+		// Responses can arrive out of order, so within a window the budget only ever shrinks.
+		reconciled := int(parsedRemaining) - b.inFlight
+		if reconciled < 0 {
+			reconciled = 0
+		}
+		if st.Estimated || newWindow || reconciled < st.Remaining {
+			st.Remaining = reconciled
+			st.Estimated = false
+		}
 	}
 
 	return nil
@@ -0,0 +1,217 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// setState overwrites the budget kept in the limiter's store
+func setState(r *RateLimiter, state RateLimitState) {
+	_ = r.store.Update(func(st *RateLimitState) error {
+		*st = state
+		return nil
+	})
+}
+
+func TestReserveContextCancel(t *testing.T) {
+	r := newRateLimiter()
+	r.clock = NewFakeClock(clockStart)
+	setState(r, RateLimitState{Remaining: 0, Reset: clockStart.Add(1 * time.Minute)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := r.reserve(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error; expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 1*time.Second {
+		t.Errorf("wait was not aborted; took %s", elapsed)
+	}
+
+	// The bucket must be usable again after an aborted wait
+	setState(r, RateLimitState{Reset: clockStart})
+	if _, err = r.reserve(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = r.release(nil)
+}
+
+func TestReserveRespectsBudget(t *testing.T) {
+	r := newRateLimiter()
+	r.clock = NewFakeClock(clockStart)
+	setState(r, RateLimitState{Remaining: 2, Reset: clockStart.Add(1 * time.Minute)})
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.reserve(context.Background()); err != nil {
+			t.Fatalf("reservation %d failed: %v", i+1, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.reserve(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the exhausted bucket to block; got: %v", err)
+	}
+	if r.bucket.inFlight != 2 {
+		t.Errorf("unexpected in-flight count; expected: 2, got: %d", r.bucket.inFlight)
+	}
+}
+
+func TestReleaseReconcilesInFlight(t *testing.T) {
+	r := newRateLimiter()
+
+	// The first response of a window is trusted even though the bucket started with a single probing token
+	if _, err := r.reserve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.release(http.Header{"X-Ratelimit-Remaining": []string{"100"}}); err != nil {
+		t.Fatal(err)
+	}
+	if r.State().Remaining != 100 {
+		t.Fatalf("unexpected remaining; expected: 100, got: %d", r.State().Remaining)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.reserve(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two requests are still in flight, so their tokens stay reserved
+	if err := r.release(http.Header{"X-Ratelimit-Remaining": []string{"99"}}); err != nil {
+		t.Fatal(err)
+	}
+	if r.State().Remaining != 97 {
+		t.Errorf("unexpected remaining; expected: 97, got: %d", r.State().Remaining)
+	}
+
+	// A late response reporting more budget must not raise the estimate
+	if err := r.release(http.Header{"X-Ratelimit-Remaining": []string{"99"}}); err != nil {
+		t.Fatal(err)
+	}
+	if r.State().Remaining != 97 {
+		t.Errorf("unexpected remaining; expected: 97, got: %d", r.State().Remaining)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	var active, peak, remaining int32 = 0, 0, 150
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		w.Header().Set("x-ratelimit-remaining", strconv.Itoa(int(atomic.AddInt32(&remaining, -1))))
+		_, _ = w.Write([]byte(channelFixture))
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	// Learn the budget first; the bucket only hands out a single probing token until then
+	if _, err := c.GetChannelByID(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Distinct channels, since identical requests would be collapsed into one
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if _, err := c.GetChannelByID(id); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak < 2 {
+		t.Errorf("requests were serialised; peak concurrency: %d", peak)
+	}
+}
+
+func TestRateLimitSnapshotAndObserver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-limit", "150")
+		w.Header().Set("x-ratelimit-remaining", "42")
+		_, _ = w.Write([]byte(categoriesFixture))
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var events []RateLimitEvent
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithRateLimitObserver(func(e RateLimitEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}))
+
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	state := c.RateLimit()
+	if state.Limit != 150 || state.Remaining != 42 || state.InFlight != 0 {
+		t.Errorf("unexpected snapshot: %+v", state)
+	}
+
+	mu.Lock()
+	if len(events) == 0 || events[len(events)-1].RateLimit != state {
+		t.Errorf("observer did not see the final state; got: %+v", events)
+	}
+	events = nil
+	mu.Unlock()
+
+	// Requests held back by an exhausted bucket are reported with their delay
+	c.limiter.exhaust(time.Now().Add(1 * time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _ = c.GetCategoriesContext(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var delayed bool
+	for _, e := range events {
+		delayed = delayed || e.Delay > 0
+	}
+	if !delayed {
+		t.Errorf("observer was not told about the delayed request; got: %+v", events)
+	}
+}
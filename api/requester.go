@@ -18,14 +18,15 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gojek/heimdall/v7"
-	"github.com/gojek/heimdall/v7/httpclient"
-	log "github.com/veteran-software/nowlive-logging"
 )
 
 var (
@@ -34,29 +35,45 @@ var (
 	exponentFactor        = 2.0
 	maximumJitterInterval = 2 * time.Millisecond
 
-	backoff = heimdall.NewExponentialBackoff(initialTimeout, maxTimeout, exponentFactor, maximumJitterInterval)
-
-	// Create a new retry mechanism with the backoff
-	retrier = heimdall.NewRetrier(backoff)
-
 	timeout = 1000 * time.Millisecond
 
-	httpClient = httpclient.NewClient(
-		httpclient.WithHTTPTimeout(timeout),
-		httpclient.WithRetrier(retrier),
-		httpclient.WithRetryCount(4),
-	)
+	// rateLimitRetries is how many times a 429 response is waited out and retried before ErrRateLimited is returned
+	rateLimitRetries = 2
 )
 
-func (r *RateLimiter) Request(method, route string, data *interface{}) (*http.Response, error) {
-	return r.requestWithLockedBucket(method, route, "application/json", data)
+func newBackoff() heimdall.Backoff {
+	return heimdall.NewExponentialBackoff(initialTimeout, maxTimeout, exponentFactor, maximumJitterInterval)
 }
 
-func (r *RateLimiter) requestWithLockedBucket(method, route, contentType string, b *interface{}) (*http.Response,
-	error) {
-	r.lockBucket()
+// newHTTPClient builds the default HTTP client; retries are handled by Client.do. A plain *http.Client is used so
+// that transport errors keep their type, e.g. *url.Error wrapping context.DeadlineExceeded, for callers to match.
+func newHTTPClient(timeout time.Duration, transport http.RoundTripper) heimdall.Doer {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+func (c *Client) Request(method, route string, data *interface{}) (*http.Response, error) {
+	return c.RequestContext(context.Background(), method, route, data)
+}
+
+// RequestContext is the same as Request, but cancelling ctx aborts the rate-limit wait or the in-flight request.
+// A response returned with a *StaleError is the last good response, served because the request failed. A call whose
+// token lacks the scopes required by the endpoint, or with ContextWithScopes, fails with a *ScopeError without being
+// sent.
+func (c *Client) RequestContext(ctx context.Context, method, route string, data *interface{}) (*http.Response, error) {
+	if err := c.checkScopes(ctx, method, route); err != nil {
+		return nil, err
+	}
+
+	return c.sharedRequest(ctx, method, route, "application/json", data)
+}
 
-	var buffer bytes.Buffer
+// request sends the request, waiting out and retrying 429 responses up to the client's rate-limit retry budget
+func (c *Client) request(ctx context.Context, method, route, contentType string, b *interface{}) (*http.Response,
+	error) {
+	var body []byte
 	if b != nil {
 		var buffer bytes.Buffer
 
@@ -64,48 +81,123 @@ func (r *RateLimiter) requestWithLockedBucket(method, route, contentType string,
 		encoder.SetEscapeHTML(false)
 		err := encoder.Encode(&b)
 		if err != nil {
-			_ = r.bucket.release(nil)
 			return nil, err
 		}
+
+		body = buffer.Bytes()
 	}
 
-	req, err := http.NewRequest(method, route, bytes.NewReader(buffer.Bytes()))
+	limited, rejected := 0, false
+	for attempt := 0; ; attempt++ {
+		token, err := c.accessToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.guardedRequest(ctx, method, route, contentType, body, c.bearer(token))
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusCreated:
+		case http.StatusNoContent:
+		case http.StatusTooManyRequests:
+			// Prefer the server's Retry-After; otherwise wait for the top of the minute
+			now := c.clock.Now()
+			wait := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+			if wait <= 0 {
+				wait = c.limiter.resetTime().Sub(now)
+			}
+			c.limiter.exhaust(now.Add(wait))
+
+			c.logger.Warn("picarto: rate limited", logKeyEndpoint, strings.TrimPrefix(route, c.baseURL), logKeyStatus,
+				resp.StatusCode, logKeyAttempt, attempt+1, logKeyWait, wait)
+
+			if limited >= c.rateLimitRetries {
+				apiErr := newAPIError(resp, c.clock.Now())
+				apiErr.RetryAfter = wait
+				return nil, apiErr
+			}
+
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+
+			if err = sleepContext(ctx, c.clock, wait); err != nil {
+				return nil, err
+			}
+
+			limited++
+			continue
+		case http.StatusUnauthorized:
+			// A token refused before its expiry, e.g. because it was revoked, is replaced and retried once
+			if token != nil && !rejected && c.rejectToken(ctx, token) {
+				rejected = true
+
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+
+				continue
+			}
+
+			return nil, newAPIError(resp, c.clock.Now())
+		default:
+			if resp.StatusCode >= http.StatusBadRequest {
+				return nil, newAPIError(resp, c.clock.Now())
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// requestWithLockedBucket makes a single request, including transport-level retries; each attempt reserves its own
+// rate-limit token, so retries count against the budget like any other request
+func (c *Client) requestWithLockedBucket(ctx context.Context, method, route, contentType string, body []byte,
+	bearer string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, route, bytes.NewReader(body))
 	if err != nil {
-		_ = r.bucket.release(nil)
 		return nil, err
 	}
 
-	if secret != nil {
-		req.Header.Set(http.CanonicalHeaderKey("Authorization"), fmt.Sprintf("Bearer %s", *secret))
+	if bearer != "" {
+		req.Header.Set(http.CanonicalHeaderKey("Authorization"), fmt.Sprintf("Bearer %s", bearer))
 	}
-	req.Header.Set("Client-ID", fmt.Sprintf("%s", token))
+	req.Header.Set("Client-ID", fmt.Sprintf("%s", c.clientID))
 	req.Header.Set(http.CanonicalHeaderKey("Content-Type"), contentType)
 
-	resp, err := httpClient.Do(req)
+	return c.do(req)
+}
+
+// reserveAttempt waits for the rate-limit token of a single attempt at req
+func (c *Client) reserveAttempt(req *http.Request) error {
+	waited, err := c.limiter.reserve(req.Context())
 	if err != nil {
-		_ = r.bucket.release(nil)
-		return nil, err
+		return err
 	}
 
-	err = r.bucket.release(resp.Header)
-	if err != nil {
-		return nil, err
+	if waited > 0 {
+		c.logger.Debug("picarto: delayed by rate limiter", logKeyEndpoint, req.URL.Path, logKeyWait, waited)
 	}
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-	case http.StatusCreated:
-	case http.StatusNoContent:
-	case http.StatusBadGateway:
-	case http.StatusTooManyRequests:
-		log.Warnln(log.Picarto, log.FuncName(), "Rate Limited!")
-		log.Infoln(log.Picarto, log.FuncName(), route)
-		log.Infoln(log.Picarto, log.FuncName(), resp.Status)
+	return nil
+}
 
-		time.Sleep(time.Until(r.bucket.reset))
+// releaseAttempt returns the token of an attempt at req, reconciling the budget with its response if there is one
+func (c *Client) releaseAttempt(req *http.Request, resp *http.Response) error {
+	if resp == nil {
+		_ = c.limiter.release(nil)
+		return nil
+	}
 
-		resp, err = r.requestWithLockedBucket(method, route, contentType, b)
+	if err := c.limiter.release(resp.Header); err != nil {
+		_ = resp.Body.Close()
+		return err
 	}
 
-	return resp, nil
+	c.logger.Debug("picarto: response received", logKeyEndpoint, req.URL.Path, logKeyStatus, resp.StatusCode,
+		logKeyRemaining, headerInt(resp.Header, "x-ratelimit-remaining"))
+
+	return nil
 }
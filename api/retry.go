@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// RetryPolicy
+//
+// Controls which failed attempts are retried. A request is retried when its method is listed in Methods and the
+// attempt either failed at the transport level or returned one of StatusCodes.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; 0 disables retrying
+	MaxRetries int
+	// Methods that may be retried; only idempotent methods should be listed here
+	Methods []string
+	// StatusCodes that trigger a retry
+	StatusCodes []int
+}
+
+// DefaultRetryPolicy retries idempotent requests up to 4 times on transport errors and transient 5xx responses
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 4,
+	Methods: []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodOptions,
+		http.MethodPut,
+		http.MethodDelete,
+	},
+	StatusCodes: []int{
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+func (p RetryPolicy) allowsMethod(method string) bool {
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p RetryPolicy) retriesStatus(status int) bool {
+	for _, s := range p.StatusCodes {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Attempt
+//
+// Describes a single HTTP attempt made by the client; passed to the hook set with WithAttemptHook
+type Attempt struct {
+	Method string
+	URL    string
+	// Number is 1 for the first attempt and increases with each retry
+	Number int
+	// StatusCode is 0 when the attempt failed before a response arrived
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+	// Retry reports whether another attempt follows, after waiting Backoff
+	Retry   bool
+	Backoff time.Duration
+}
+
+// do sends req, retrying according to the client's retry policy. Every attempt reserves and releases its own
+// rate-limit token.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	retryable := c.retryPolicy.allowsMethod(req.Method)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		if err := c.reserveAttempt(req); err != nil {
+			return nil, err
+		}
+
+		start := c.clock.Now()
+		resp, err := c.httpClient.Do(req)
+		// Whatever a Doer makes of a cancelled request, the caller is told it was cancelled
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			if resp != nil {
+				_ = resp.Body.Close()
+			}
+			resp, err = nil, ctxErr
+		}
+		elapsed := c.clock.Now().Sub(start)
+
+		if releaseErr := c.releaseAttempt(req, resp); releaseErr != nil {
+			return nil, releaseErr
+		}
+
+		a := Attempt{
+			Method:   req.Method,
+			URL:      req.URL.String(),
+			Number:   attempt + 1,
+			Err:      err,
+			Duration: elapsed,
+		}
+		if resp != nil {
+			a.StatusCode = resp.StatusCode
+		}
+
+		a.Retry = retryable && attempt < c.retryPolicy.MaxRetries && req.Context().Err() == nil &&
+			(err != nil || c.retryPolicy.retriesStatus(resp.StatusCode))
+		if a.Retry {
+			a.Backoff = c.backoff.Next(attempt)
+		}
+
+		if c.onAttempt != nil {
+			c.onAttempt(a)
+		}
+
+		if a.Retry {
+			c.logger.Warn("picarto: retrying request", logKeyEndpoint, req.URL.Path, logKeyStatus, a.StatusCode,
+				logKeyAttempt, a.Number, logKeyWait, a.Backoff, logKeyError, err)
+		} else if err != nil {
+			c.logger.Error("picarto: request failed", logKeyEndpoint, req.URL.Path, logKeyAttempt, a.Number,
+				logKeyError, err)
+		}
+
+		if !a.Retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+
+		if err = sleepContext(req.Context(), c.clock, a.Backoff); err != nil {
+			return nil, err
+		}
+	}
+}
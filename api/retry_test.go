@@ -0,0 +1,282 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojek/heimdall/v7"
+)
+
+// newFlakyServer fails the first failures requests with status and then serves the categories fixture
+func newFlakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		writeFixture(w, categoriesFixture)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func TestRetryTransientStatus(t *testing.T) {
+	srv, calls := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+	var attempts []Attempt
+	c := NewClient("TEST_TOKEN",
+		WithBaseURL(srv.URL),
+		WithBackoff(heimdall.NewConstantBackoff(0, 0)),
+		WithAttemptHook(func(a Attempt) {
+			attempts = append(attempts, a)
+		}),
+	)
+
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	if *calls != 3 {
+		t.Errorf("unexpected number of requests; expected: 3, got: %d", *calls)
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("unexpected number of reported attempts; expected: 3, got: %d", len(attempts))
+	}
+	for i, a := range attempts {
+		if a.Number != i+1 {
+			t.Errorf("unexpected attempt number; expected: %d, got: %d", i+1, a.Number)
+		}
+		if retry := i < 2; a.Retry != retry {
+			t.Errorf("attempt %d: unexpected retry flag; expected: %t, got: %t", a.Number, retry, a.Retry)
+		}
+	}
+	if attempts[0].StatusCode != http.StatusServiceUnavailable || attempts[2].StatusCode != http.StatusOK {
+		t.Errorf("unexpected status codes reported: %d, %d", attempts[0].StatusCode, attempts[2].StatusCode)
+	}
+}
+
+func TestRetryPolicyLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		method string
+		status int
+		calls  int32
+	}{
+		{"max retries", RetryPolicy{MaxRetries: 1, Methods: DefaultRetryPolicy.Methods,
+			StatusCodes: DefaultRetryPolicy.StatusCodes}, http.MethodGet, http.StatusBadGateway, 2},
+		{"non-idempotent method", DefaultRetryPolicy, http.MethodPost, http.StatusBadGateway, 1},
+		{"status not listed", DefaultRetryPolicy, http.MethodGet, http.StatusNotFound, 1},
+		{"disabled", RetryPolicy{}, http.MethodGet, http.StatusBadGateway, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := newFlakyServer(t, 10, tt.status)
+
+			c := NewClient("TEST_TOKEN", WithRetryPolicy(tt.policy), WithBackoff(heimdall.NewConstantBackoff(0, 0)))
+
+			_, err := c.Request(tt.method, srv.URL+"/categories", nil)
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Errorf("unexpected error; expected status %d, got: %v", tt.status, err)
+			}
+			if *calls != tt.calls {
+				t.Errorf("unexpected number of requests; expected: %d, got: %d", tt.calls, *calls)
+			}
+		})
+	}
+}
+
+func TestRateLimitedRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeFixture(w, categoriesFixture)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	start := time.Now()
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("unexpected number of requests; expected: 2, got: %d", calls)
+	}
+	if elapsed := time.Since(start); elapsed < 1*time.Second {
+		t.Errorf("Retry-After was not honoured; retried after %s", elapsed)
+	}
+}
+
+func TestRateLimitedExhausted(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("x-ratelimit-remaining", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithRateLimitRetries(0))
+
+	_, err := c.GetCategories()
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("unexpected error; expected: %v, got: %v", ErrRateLimited, err)
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("unexpected wait time; expected: 30s, got: %s", apiErr.RetryAfter)
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of requests; expected: 1, got: %d", calls)
+	}
+
+	// The rest of the client must now hold off until the wait is over
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err = c.GetCategoriesContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the bucket to be exhausted; got: %v", err)
+	}
+}
+
+// newSlowServer answers after delay, or gives up when the request is cancelled
+func newSlowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("x-ratelimit-remaining", "100")
+		writeFixture(w, categoriesFixture)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestRequestCancelledInFlight(t *testing.T) {
+	srv := newSlowServer(t, 300*time.Millisecond)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL))
+
+	// The rate-limit wait is long over by the time the deadline passes; the request itself is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.RequestContext(ctx, http.MethodPost, srv.URL+"/categories", nil); !errors.Is(err,
+		context.DeadlineExceeded) {
+		t.Errorf("unexpected error; expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("request was not cancelled; took: %s", elapsed)
+	}
+}
+
+func TestRequestTimeoutIsNetError(t *testing.T) {
+	srv := newSlowServer(t, 300*time.Millisecond)
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithTimeout(50*time.Millisecond), WithRetryPolicy(RetryPolicy{}))
+
+	_, err := c.GetCategories()
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("unexpected error; expected a timeout net.Error, got: %T %v", err, err)
+	}
+}
+
+func TestRetryReservesToken(t *testing.T) {
+	srv, calls := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+	var mu sync.Mutex
+	var reserved int
+	c := NewClient("TEST_TOKEN",
+		WithBaseURL(srv.URL),
+		WithBackoff(heimdall.NewConstantBackoff(0, 0)),
+		WithRateLimitObserver(func(e RateLimitEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if e.InFlight == 1 && e.Delay == 0 {
+				reserved++
+			}
+		}),
+	)
+
+	if _, err := c.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if n := atomic.LoadInt32(calls); int(n) != reserved {
+		t.Errorf("every attempt should reserve its own token; requests: %d, tokens reserved: %d", n, reserved)
+	}
+}
+
+// doerFunc adapts a function to heimdall.Doer
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRateLimitedWithoutResponseRequest(t *testing.T) {
+	// A Doer is free to leave Response.Request unset
+	doer := doerFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header: http.Header{
+				"Retry-After":           []string{"30"},
+				"X-Ratelimit-Remaining": []string{"0"},
+			},
+			Body: io.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+
+	c := NewClient("TEST_TOKEN", WithHTTPClient(doer), WithRateLimitRetries(0))
+
+	var apiErr *APIError
+	if _, err := c.GetCategories(); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("unexpected error; expected status %d, got: %v", http.StatusTooManyRequests, err)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/veteran-software/picarto-api-wrapper/oauth"
+)
+
+// ScopeError
+//
+// Returned, before any request is made, when the token a call would be sent with lacks scopes the call requires
+type ScopeError struct {
+	Missing []oauth.Scope
+}
+
+func (e *ScopeError) Error() string {
+	missing := make([]string, len(e.Missing))
+	for i, scope := range e.Missing {
+		missing[i] = string(scope)
+	}
+
+	return fmt.Sprintf("picarto: insufficient scope: missing %s", strings.Join(missing, ", "))
+}
+
+// Is allows errors.Is to match a ScopeError against ErrInsufficientScope
+func (e *ScopeError) Is(target error) bool {
+	return target == ErrInsufficientScope
+}
+
+// endpointScope lists the scopes required by the endpoint at method and path. A "*" in the path matches any single
+// segment, such as a channel ID. The userScopes are only required of calls made for a user with ContextWithUserToken
+// or ContextWithTokenSource, for endpoints that answer anyone but fill in more for a user who granted them.
+type endpointScope struct {
+	method     string
+	path       string
+	scopes     []oauth.Scope
+	userScopes []oauth.Scope
+}
+
+// endpointScopes holds the scopes each endpoint requires; RequestContext checks them before every request. Endpoints
+// acting for a user belong here along with the scopes Picarto asks for. Routes not listed require none.
+var endpointScopes = []endpointScope{
+	{http.MethodGet, "/categories", nil, nil},
+	{http.MethodGet, "/channel/id/*", nil, []oauth.Scope{oauth.ScopeReadPub}},
+	{http.MethodGet, "/channel/name/*", nil, []oauth.Scope{oauth.ScopeReadPub}},
+	{http.MethodGet, "/channel/id/*/videos", nil, nil},
+	{http.MethodGet, "/channel/name/*/videos", nil, nil},
+	{http.MethodGet, "/channel/id/*/streams", nil, nil},
+	{http.MethodGet, "/channel/name/*/streams", nil, nil},
+	{http.MethodGet, "/online", nil, []oauth.Scope{oauth.ScopeReadPub}},
+	{http.MethodGet, "/search/channels", nil, nil},
+	{http.MethodGet, "/search/videos", nil, nil},
+	{http.MethodGet, "/notifications", nil, nil},
+}
+
+// routeScopes returns the scopes the endpoint at method and route requires, including those it only requires of
+// calls made for a user when forUser is set
+func (c *Client) routeScopes(method, route string, forUser bool) []oauth.Scope {
+	if !strings.HasPrefix(route, c.baseURL) {
+		return nil
+	}
+	path := strings.TrimPrefix(route, c.baseURL)
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	for _, e := range endpointScopes {
+		if e.method == method && matchPath(e.path, path) {
+			if forUser {
+				return append(append([]oauth.Scope(nil), e.scopes...), e.userScopes...)
+			}
+
+			return e.scopes
+		}
+	}
+
+	return nil
+}
+
+// matchPath reports whether path matches pattern segment by segment, where "*" matches any one segment
+func matchPath(pattern, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+
+	for i, segment := range patternSegments {
+		if segment != "*" && segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type scopesKey struct{}
+
+// ContextWithScopes returns a copy of ctx whose requests require scopes, on top of any already required by ctx. Use it
+// when a call is only useful with a scope the endpoint does not insist on, e.g. ScopeReadPub for the Following flag
+// of GetChannelByName sent with the client's own token source, so a token without it fails fast instead of quietly
+// answering false.
+//
+//goland:noinspection GoUnusedExportedFunction
+func ContextWithScopes(ctx context.Context, scopes ...oauth.Scope) context.Context {
+	required := append(append([]oauth.Scope(nil), scopesFrom(ctx)...), scopes...)
+
+	return context.WithValue(ctx, scopesKey{}, required)
+}
+
+// scopesFrom returns the scopes required by ctx
+func scopesFrom(ctx context.Context) []oauth.Scope {
+	scopes, _ := ctx.Value(scopesKey{}).([]oauth.Scope)
+
+	return scopes
+}
+
+// checkScopes returns a *ScopeError unless the token a request made with ctx is sent with grants the scopes required
+// by the endpoint and by ctx. A request sent with the client secret has no token, so it is not known to grant any.
+func (c *Client) checkScopes(ctx context.Context, method, route string) error {
+	_, forUser := userAuthFrom(ctx)
+	required := append(append([]oauth.Scope(nil), c.routeScopes(method, route, forUser)...), scopesFrom(ctx)...)
+	if len(required) == 0 {
+		return nil
+	}
+
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	if missing := token.MissingScopes(required...); len(missing) > 0 {
+		return &ScopeError{Missing: missing}
+	}
+
+	return nil
+}
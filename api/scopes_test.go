@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/veteran-software/picarto-api-wrapper/oauth"
+)
+
+func TestContextWithScopes(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("x-ratelimit-remaining", "100")
+		writeFixture(w, channelFixture)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClientSecret("SECRET"))
+
+	granted := &oauth.Token{AccessToken: "granted", Scopes: []oauth.Scope{oauth.ScopeReadPub}}
+	ctx := ContextWithScopes(ContextWithUserToken(context.Background(), granted), oauth.ScopeReadPub)
+	if _, err := c.GetChannelByNameContext(ctx, "AgueMort"); err != nil {
+		t.Fatalf("call with the required scope failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		missing []oauth.Scope
+	}{
+		{
+			name: "token without the scope",
+			ctx: ContextWithScopes(ContextWithUserToken(context.Background(), &oauth.Token{AccessToken: "none"}),
+				oauth.ScopeReadPub),
+			missing: []oauth.Scope{oauth.ScopeReadPub},
+		},
+		{
+			name: "required twice",
+			ctx: ContextWithScopes(ContextWithScopes(ContextWithUserToken(context.Background(), granted),
+				oauth.ScopeReadPriv), oauth.ScopeReadPub, oauth.ScopeReadPriv),
+			missing: []oauth.Scope{oauth.ScopeReadPriv},
+		},
+		{
+			name:    "client secret",
+			ctx:     ContextWithScopes(context.Background(), oauth.ScopeReadPub),
+			missing: []oauth.Scope{oauth.ScopeReadPub},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.GetChannelByNameContext(tt.ctx, "AgueMort")
+			if !errors.Is(err, ErrInsufficientScope) {
+				t.Fatalf("unexpected error; expected: %v, got: %v", ErrInsufficientScope, err)
+			}
+
+			var scopeErr *ScopeError
+			if !errors.As(err, &scopeErr) || !reflect.DeepEqual(scopeErr.Missing, tt.missing) {
+				t.Errorf("unexpected missing scopes; expected: %v, got: %v", tt.missing, err)
+			}
+		})
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("calls lacking scopes were sent; request count: %d", n)
+	}
+}
+
+func TestEndpointScopes(t *testing.T) {
+	listed := endpointScopes
+	endpointScopes = append(append([]endpointScope(nil), listed...),
+		endpointScope{http.MethodGet, "/user/*/private", []oauth.Scope{oauth.ScopeReadPriv}, nil})
+	t.Cleanup(func() {
+		endpointScopes = listed
+	})
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("x-ratelimit-remaining", "100")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClientSecret("SECRET"))
+
+	// A listed endpoint fails fast without the caller asking for its scopes
+	if _, err := c.Request(http.MethodGet, srv.URL+"/user/1/private?page=2", nil); !errors.Is(err,
+		ErrInsufficientScope) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrInsufficientScope, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("a call lacking scopes was sent; request count: %d", n)
+	}
+
+	granted := &oauth.Token{AccessToken: "granted", Scopes: []oauth.Scope{oauth.ScopeReadPriv}}
+	resp, err := c.RequestContext(ContextWithUserToken(context.Background(), granted), http.MethodGet,
+		srv.URL+"/user/1/private", nil)
+	if err != nil {
+		t.Fatalf("call with the required scope failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// Other methods and paths are not affected
+	for _, route := range []string{"/user/1/private/more", "/user/private"} {
+		resp, err = c.Request(http.MethodGet, srv.URL+route, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", route, err)
+		}
+		_ = resp.Body.Close()
+	}
+}
+
+func TestEndpointUserScopes(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("x-ratelimit-remaining", "100")
+		if r.URL.Path == "/online" {
+			_, _ = w.Write([]byte("[]"))
+			return
+		}
+		writeFixture(w, channelFixture)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithClientSecret("SECRET"))
+
+	// A user's token must grant ScopeReadPub, or Following could not be filled in
+	user := ContextWithUserToken(context.Background(), &oauth.Token{AccessToken: "none"})
+	if _, err := c.GetChannelByNameContext(user, "AgueMort"); !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("GetChannelByName: unexpected error; expected: %v, got: %v", ErrInsufficientScope, err)
+	}
+	if _, err := c.GetChannelByIDContext(user, 1); !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("GetChannelByID: unexpected error; expected: %v, got: %v", ErrInsufficientScope, err)
+	}
+	if _, err := c.GetOnlineContext(user, OnlineQuery{}); !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("GetOnline: unexpected error; expected: %v, got: %v", ErrInsufficientScope, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("calls lacking scopes were sent; request count: %d", n)
+	}
+
+	granted := ContextWithUserToken(context.Background(),
+		&oauth.Token{AccessToken: "granted", Scopes: []oauth.Scope{oauth.ScopeReadPub}})
+	if _, err := c.GetChannelByNameContext(granted, "AgueMort"); err != nil {
+		t.Errorf("call with the required scope failed: %v", err)
+	}
+
+	// The endpoints stay public for calls that are not made for a user
+	if _, err := c.GetChannelByName("AgueMort"); err != nil {
+		t.Errorf("call with the client secret failed: %v", err)
+	}
+	if _, err := c.GetOnline(OnlineQuery{}); err != nil {
+		t.Errorf("call with the client secret failed: %v", err)
+	}
+}
+
+func TestEndpointScopesListEveryEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path] = true
+		mu.Unlock()
+
+		w.Header().Set("x-ratelimit-remaining", "100")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewClient("TEST_TOKEN", WithBaseURL(srv.URL), WithRetryPolicy(RetryPolicy{}))
+
+	_, _ = c.GetCategories()
+	_, _ = c.GetChannelByID(1)
+	_, _ = c.GetChannelByName("AgueMort")
+	_, _ = c.GetAllChannelVideosByChannelID(1)
+	_, _ = c.GetAllChannelVideosByChannelName("AgueMort")
+	_, _ = c.GetOnline(OnlineQuery{})
+	_, _ = c.SearchChannels(ChannelSearchQuery{Query: "art"})
+	_, _ = c.SearchVideos(VideoSearchQuery{Query: "art"})
+	_, _ = c.GetStreamByChannelID(1)
+	_, _ = c.GetStreamByChannelName("AgueMort")
+	_, _ = c.GetNotifications()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(paths) != 11 {
+		t.Errorf("unexpected number of endpoints reached; expected: 11, got: %d", len(paths))
+	}
+	for path := range paths {
+		var found bool
+		for _, e := range endpointScopes {
+			found = found || (e.method == http.MethodGet && matchPath(e.path, path))
+		}
+		if !found {
+			t.Errorf("endpoint %s is missing from endpointScopes", path)
+		}
+	}
+}
@@ -1,6 +0,0 @@
-package api
-
-var (
-	token  string
-	secret *string
-)
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimitState
+//
+// The per-minute budget shared by every client drawing from the same RateLimitStore. The zero value is a valid
+// starting state; the limiter begins a fresh window the first time it sees it.
+type RateLimitState struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+	// Estimated is set while Remaining is a local guess rather than a value reported by Picarto for this window
+	Estimated bool `json:"estimated"`
+	// Throttled is the end of the window in which Picarto answered 429 Too Many Requests. While it is set, Reset is the
+	// deadline given by Retry-After, which only a response from a later window may move.
+	Throttled time.Time `json:"throttled,omitempty"`
+}
+
+// RateLimitStore
+//
+// Holds the rate-limit budget behind a RateLimiter. Clients that share a store, within one process or across
+// processes, draw from a single coordinated budget.
+type RateLimitStore interface {
+	// Update calls fn with the current state and saves whatever fn leaves in it. The whole call must be atomic with
+	// respect to every other Update on the same store, including those made by other processes. If fn returns an
+	// error, the changes it made are still saved and the error is returned.
+	Update(fn func(state *RateLimitState) error) error
+}
+
+// MemoryRateLimitStore
+//
+// The default RateLimitStore. It is private to the process, but one instance may be shared by several clients.
+type MemoryRateLimitStore struct {
+	sync.Mutex
+
+	state RateLimitState
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{}
+}
+
+func (s *MemoryRateLimitStore) Update(fn func(state *RateLimitState) error) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return fn(&s.state)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileRateLimitStore
+//
+// A RateLimitStore kept in a small JSON file guarded by an exclusive file lock, so that every process on the host
+// opening the same path (e.g. several bot shards using one Client-ID) draws from a single budget. File locking is
+// only available on Unix-like systems; elsewhere Update returns an error.
+type FileRateLimitStore struct {
+	// The file lock is held per open file, so goroutines of this process are serialised separately
+	sync.Mutex
+
+	file *os.File
+}
+
+// NewFileRateLimitStore opens, or creates, the state file at path
+func NewFileRateLimitStore(path string) (*FileRateLimitStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileRateLimitStore{file: file}, nil
+}
+
+func (s *FileRateLimitStore) Update(fn func(state *RateLimitState) error) (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err = lockFile(s.file); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlockFile(s.file); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	if _, err = s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(s.file)
+	if err != nil {
+		return err
+	}
+
+	// An empty or unreadable file starts from the zero state
+	var state RateLimitState
+	if len(data) > 0 {
+		_ = json.Unmarshal(data, &state)
+	}
+	before := state
+
+	fnErr := fn(&state)
+
+	if state != before {
+		if err = s.write(state); err != nil {
+			return err
+		}
+	}
+
+	return fnErr
+}
+
+func (s *FileRateLimitStore) write(state RateLimitState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err = s.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = s.file.WriteAt(data, 0)
+
+	return err
+}
+
+// Close releases the state file; the store must not be used afterwards
+func (s *FileRateLimitStore) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.file.Close()
+}
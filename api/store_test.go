@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package api
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSharedMemoryStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	a := NewClient("CLIENT_ID", WithRateLimitStore(store))
+	b := NewClient("CLIENT_ID", WithRateLimitStore(store))
+
+	setState(a.limiter, RateLimitState{Limit: 150, Remaining: 1, Reset: time.Now().Add(1 * time.Minute)})
+
+	if _, err := a.limiter.reserve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The only token was taken by the other client
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := b.limiter.reserve(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the shared budget to be exhausted; got: %v", err)
+	}
+}
+
+func TestFileRateLimitStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+
+	// Separate handles on the same file stand in for separate processes
+	stores := make([]*FileRateLimitStore, 4)
+	for i := range stores {
+		s, err := NewFileRateLimitStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = s.Close()
+		}()
+		stores[i] = s
+	}
+
+	if err := stores[0].Update(func(st *RateLimitState) error {
+		st.Limit = 150
+		st.Remaining = 100
+		st.Reset = time.Now().Add(1 * time.Minute)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range stores {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(s *FileRateLimitStore) {
+				defer wg.Done()
+				if err := s.Update(func(st *RateLimitState) error {
+					st.Remaining--
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			}(s)
+		}
+	}
+	wg.Wait()
+
+	var state RateLimitState
+	if err := stores[3].Update(func(st *RateLimitState) error {
+		state = *st
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Limit != 150 || state.Remaining != 60 {
+		t.Errorf("updates were lost; expected 60 of 150 remaining, got: %+v", state)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	// ErrInvalidGrant is matched by an *Error for a code, verifier or refresh token the server rejected
+	ErrInvalidGrant = errors.New("oauth: invalid grant")
+)
+
+// Error
+//
+// Returned when the token endpoint refuses a request; Code and Description come from the RFC 6749 error response
+type Error struct {
+	StatusCode  int
+	Code        string
+	Description string
+	Body        []byte
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("oauth: token request failed with status %d", e.StatusCode)
+	}
+	if e.Description == "" {
+		return fmt.Sprintf("oauth: %s", e.Code)
+	}
+
+	return fmt.Sprintf("oauth: %s: %s", e.Code, e.Description)
+}
+
+// Is allows errors.Is to match an Error against ErrInvalidGrant
+func (e *Error) Is(target error) bool {
+	return target == ErrInvalidGrant && e.Code == "invalid_grant"
+}
+
+// newError builds an Error from a failed token response
+func newError(resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	e := &Error{StatusCode: resp.StatusCode, Body: body}
+
+	var parsed struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		e.Code = parsed.Error
+		e.Description = parsed.ErrorDescription
+	}
+
+	return e
+}
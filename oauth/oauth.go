@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package oauth implements Picarto's OAuth 2.0 authorization-code flow with PKCE, for obtaining user access tokens to
+// use with the api package.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// AuthorizeURL is where users are sent to grant access
+	AuthorizeURL = "https://oauth.picarto.tv/authorize"
+	// TokenURL is where codes and refresh tokens are exchanged for access tokens
+	TokenURL = "https://oauth.picarto.tv/token"
+)
+
+// maxErrorBody caps how much of a failed token response is read
+const maxErrorBody = 64 << 10
+
+// Config
+//
+// The OAuth settings of a Picarto application
+type Config struct {
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []Scope
+
+	authURL    string
+	tokenURL   string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// Option configures a Config during construction
+type Option func(*Config)
+
+// WithClientSecret sets the client secret sent with token requests; public clients relying on PKCE alone can omit it
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithClientSecret(clientSecret string) Option {
+	return func(c *Config) {
+		c.clientSecret = clientSecret
+	}
+}
+
+// WithScopes sets the scopes requested in the authorize URL
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithScopes(scopes ...Scope) Option {
+	return func(c *Config) {
+		c.scopes = scopes
+	}
+}
+
+// WithEndpoint overrides the authorize and token URLs, e.g. to point the config at a local httptest.Server
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithEndpoint(authURL, tokenURL string) Option {
+	return func(c *Config) {
+		c.authURL = authURL
+		c.tokenURL = tokenURL
+	}
+}
+
+// WithHTTPClient sets the client used for token requests; http.DefaultClient is used otherwise
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.httpClient = client
+	}
+}
+
+// NewConfig
+//
+// Creates the OAuth settings for the application with clientID, which must have redirectURL registered
+//
+//goland:noinspection GoUnusedExportedFunction
+func NewConfig(clientID, redirectURL string, opts ...Option) *Config {
+	c := &Config{
+		clientID:    clientID,
+		redirectURL: redirectURL,
+		authURL:     AuthorizeURL,
+		tokenURL:    TokenURL,
+		httpClient:  http.DefaultClient,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// AuthCodeURL
+//
+// Returns the URL to send the user to. state is echoed back to the redirect URL and must be checked there; verifier
+// must be kept for Exchange. Both can be made with GenerateState and GenerateVerifier.
+func (c *Config) AuthCodeURL(state, verifier string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", c.clientID)
+	v.Set("redirect_uri", c.redirectURL)
+	if len(c.scopes) > 0 {
+		v.Set("scope", joinScopes(c.scopes))
+	}
+	v.Set("state", state)
+	v.Set("code_challenge", S256Challenge(verifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(c.authURL, "?") {
+		sep = "&"
+	}
+
+	return c.authURL + sep + v.Encode()
+}
+
+// Exchange
+//
+// Trades the code received at the redirect URL, along with the verifier used for AuthCodeURL, for a token
+func (c *Config) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", c.redirectURL)
+	v.Set("code_verifier", verifier)
+
+	token, err := c.token(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 6749 leaves the scope out when it is the one that was asked for
+	if len(token.Scopes) == 0 && len(c.scopes) > 0 {
+		token.Scopes = append([]Scope(nil), c.scopes...)
+	}
+
+	return token, nil
+}
+
+// Refresh
+//
+// Trades a refresh token for a new token. If the response carries no new refresh token, the old one is kept. If it
+// carries no scope, the scope is the one originally granted, which the caller has to carry over.
+func (c *Config) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	v := url.Values{}
+	v.Set("grant_type", "refresh_token")
+	v.Set("refresh_token", refreshToken)
+
+	token, err := c.token(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+
+	return token, nil
+}
+
+// tokenResponse is the body of a successful token response
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+// token posts form to the token endpoint and decodes the token it returns
+func (c *Config) token(ctx context.Context, form url.Values) (*Token, error) {
+	form.Set("client_id", c.clientID)
+	if c.clientSecret != "" {
+		form.Set("client_secret", c.clientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newError(resp)
+	}
+
+	var body tokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("oauth: unable to decode token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("oauth: token response has no access token")
+	}
+
+	token := &Token{
+		AccessToken:  body.AccessToken,
+		TokenType:    body.TokenType,
+		RefreshToken: body.RefreshToken,
+		Scopes:       ParseScopes(body.Scope),
+	}
+	if body.ExpiresIn > 0 {
+		token.Expiry = c.now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
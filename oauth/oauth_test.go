@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authServer is a stand-in for Picarto's authorization server. It issues codes bound to a PKCE challenge and
+// exchanges them, and refresh tokens, for tokens.
+type authServer struct {
+	sync.Mutex
+	*httptest.Server
+
+	challenges map[string]string
+	issued     int
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	s := &authServer{challenges: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.token))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// authorize stands in for the user approving the request at authURL; it returns the code sent to the redirect URL
+func (s *authServer) authorize(t *testing.T, authURL string) (code string, redirect url.Values) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected challenge method: %q", q.Get("code_challenge_method"))
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	code = "code-" + q.Get("state")
+	s.challenges[code] = q.Get("code_challenge")
+
+	return code, q
+}
+
+func (s *authServer) token(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := r.ParseForm(); err != nil || r.Form.Get("client_id") != "CLIENT_ID" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+		return
+	}
+
+	switch r.Form.Get("grant_type") {
+	case "authorization_code":
+		code := r.Form.Get("code")
+		challenge, ok := s.challenges[code]
+		if !ok || challenge != S256Challenge(r.Form.Get("code_verifier")) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"code verifier does not match"}`))
+			return
+		}
+		delete(s.challenges, code)
+	case "refresh_token":
+		if r.Form.Get("refresh_token") != "refresh-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"unsupported_grant_type"}`))
+		return
+	}
+
+	s.issued++
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"access_token":"access-` + strconv.Itoa(s.issued) +
+		`","token_type":"Bearer","refresh_token":"refresh-1","expires_in":3600,"scope":"readpub readpriv"}`))
+}
+
+func newTestConfig(s *authServer) *Config {
+	c := NewConfig("CLIENT_ID", "http://localhost/callback",
+		WithScopes(ScopeReadPub, ScopeReadPriv),
+		WithEndpoint(s.URL+"/authorize", s.URL+"/token"))
+	c.now = func() time.Time {
+		return time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	}
+
+	return c
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	s := newAuthServer(t)
+	c := newTestConfig(s)
+
+	state, err := GenerateState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := GenerateVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, q := s.authorize(t, c.AuthCodeURL(state, verifier))
+	want := map[string]string{
+		"response_type": "code",
+		"client_id":     "CLIENT_ID",
+		"redirect_uri":  "http://localhost/callback",
+		"scope":         "readpub readpriv",
+		"state":         state,
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("authorize URL parameter %s; expected: %q, got: %q", k, v, q.Get(k))
+		}
+	}
+
+	token, err := c.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" || token.TokenType != "Bearer" {
+		t.Errorf("unexpected token: %+v", token)
+	}
+	if !reflect.DeepEqual(token.Scopes, []Scope{ScopeReadPub, ScopeReadPriv}) {
+		t.Errorf("unexpected scopes: %v", token.Scopes)
+	}
+	if want := c.now().Add(1 * time.Hour); !token.Expiry.Equal(want) {
+		t.Errorf("unexpected expiry; expected: %s, got: %s", want, token.Expiry)
+	}
+	if !token.ValidAt(c.now()) || token.ValidAt(c.now().Add(1*time.Hour)) {
+		t.Error("token validity does not follow its expiry")
+	}
+
+	refreshed, err := c.Refresh(context.Background(), token.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.AccessToken != "access-2" {
+		t.Errorf("unexpected refreshed token: %+v", refreshed)
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	s := newAuthServer(t)
+	c := newTestConfig(s)
+
+	code, _ := s.authorize(t, c.AuthCodeURL("state", "right-verifier"))
+
+	_, err := c.Exchange(context.Background(), code, "wrong-verifier")
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) || !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if oauthErr.StatusCode != http.StatusBadRequest || oauthErr.Description != "code verifier does not match" {
+		t.Errorf("unexpected error details: %+v", oauthErr)
+	}
+}
+
+func TestS256Challenge(t *testing.T) {
+	// The example from RFC 7636, appendix B
+	if got := S256Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got !=
+		"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("unexpected challenge: %s", got)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package oauth
+
+import "strings"
+
+// Scope
+//
+// A permission a user grants the application when authorizing it
+type Scope string
+
+const (
+	// ScopeReadPub reads the user's public information, such as the channels they follow
+	ScopeReadPub Scope = "readpub"
+	// ScopeWritePub changes the user's public information
+	ScopeWritePub Scope = "writepub"
+	// ScopeReadPriv reads the user's private information
+	ScopeReadPriv Scope = "readpriv"
+	// ScopeWritePriv changes the user's private information
+	ScopeWritePriv Scope = "writepriv"
+)
+
+// ParseScopes splits the space-separated scope parameter of RFC 6749 into its scopes
+func ParseScopes(s string) []Scope {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	scopes := make([]Scope, len(fields))
+	for i, field := range fields {
+		scopes[i] = Scope(field)
+	}
+
+	return scopes
+}
+
+// joinScopes builds the space-separated scope parameter of RFC 6749
+func joinScopes(scopes []Scope) string {
+	fields := make([]string, len(scopes))
+	for i, scope := range scopes {
+		fields[i] = string(scope)
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	if scopes := ParseScopes(" readpub  writepriv "); !reflect.DeepEqual(scopes, []Scope{ScopeReadPub, ScopeWritePriv}) {
+		t.Errorf("unexpected scopes: %v", scopes)
+	}
+	if scopes := ParseScopes(""); scopes != nil {
+		t.Errorf("unexpected scopes for an empty parameter: %v", scopes)
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	token := &Token{AccessToken: "access", Scopes: []Scope{ScopeReadPub, ScopeReadPriv}}
+
+	if missing := token.MissingScopes(ScopeWritePub, ScopeReadPub, ScopeWritePriv); !reflect.DeepEqual(missing,
+		[]Scope{ScopeWritePub, ScopeWritePriv}) {
+		t.Errorf("unexpected missing scopes: %v", missing)
+	}
+	if !token.HasScopes(ScopeReadPriv) || !token.HasScopes() {
+		t.Error("granted scopes are reported missing")
+	}
+
+	var none *Token
+	if missing := none.MissingScopes(ScopeReadPub); !reflect.DeepEqual(missing, []Scope{ScopeReadPub}) {
+		t.Errorf("unexpected missing scopes without a token: %v", missing)
+	}
+}
+
+// newScopelessConfig asks for scopes from a token endpoint that never says which scopes it granted
+func newScopelessConfig(t *testing.T, scopes ...Scope) *Config {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return NewConfig("CLIENT_ID", "http://localhost/callback",
+		WithScopes(scopes...),
+		WithEndpoint(srv.URL+"/authorize", srv.URL+"/token"))
+}
+
+func TestTokenScopesDefaultToRequested(t *testing.T) {
+	c := newScopelessConfig(t, ScopeReadPub)
+
+	token, err := c.Exchange(context.Background(), "code", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(token.Scopes, []Scope{ScopeReadPub}) {
+		t.Errorf("unexpected scopes; expected: %v, got: %v", []Scope{ScopeReadPub}, token.Scopes)
+	}
+}
+
+func TestRefreshKeepsGrantedScopes(t *testing.T) {
+	c := newScopelessConfig(t, ScopeReadPub, ScopeReadPriv)
+
+	// The user granted less than was asked for
+	source := c.TokenSource(&Token{AccessToken: "revoked", RefreshToken: "refresh", Scopes: []Scope{ScopeReadPub}}, nil)
+
+	token, err := source.Reject(context.Background(), &Token{AccessToken: "revoked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(token.Scopes, []Scope{ScopeReadPub}) {
+		t.Errorf("unexpected scopes after a refresh; expected: %v, got: %v", []Scope{ScopeReadPub}, token.Scopes)
+	}
+}
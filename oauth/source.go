@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package oauth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoRefreshToken is returned when a token has expired, or was rejected, and cannot be refreshed
+var ErrNoRefreshToken = errors.New("oauth: token cannot be refreshed without a refresh token")
+
+// TokenSource
+//
+// Supplies the access token for each request. Implementations must be safe for concurrent use.
+type TokenSource interface {
+	// Token returns a token that is valid for at least the next few seconds
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenRejecter
+//
+// Implemented by token sources that can replace a token the server refused, e.g. one revoked before its expiry
+type TokenRejecter interface {
+	// Reject reports that token was refused and returns a replacement. Several callers may reject the same token;
+	// it is only replaced once.
+	Reject(ctx context.Context, token *Token) (*Token, error)
+}
+
+// StaticTokenSource
+//
+// Returns a TokenSource that always returns token, whether it has expired or not
+func StaticTokenSource(token *Token) TokenSource {
+	return staticTokenSource{token: token}
+}
+
+type staticTokenSource struct {
+	token *Token
+}
+
+func (s staticTokenSource) Token(context.Context) (*Token, error) {
+	return s.token, nil
+}
+
+// RefreshingTokenSource
+//
+// A TokenSource that refreshes its token shortly before it expires, or when it is rejected. Concurrent callers wait
+// for, and share, a single refresh.
+type RefreshingTokenSource struct {
+	config  *Config
+	persist func(*Token)
+
+	// sem holds the token; it is taken for the whole refresh so callers share it instead of starting their own
+	sem   chan struct{}
+	token *Token
+}
+
+// TokenSource
+//
+// Returns a RefreshingTokenSource starting from token. persist, if not nil, is called with every newly issued token,
+// so it can be stored and loaded again after a restart.
+func (c *Config) TokenSource(token *Token, persist func(*Token)) *RefreshingTokenSource {
+	return &RefreshingTokenSource{
+		config:  c,
+		persist: persist,
+		sem:     make(chan struct{}, 1),
+		token:   token,
+	}
+}
+
+func (s *RefreshingTokenSource) Token(ctx context.Context) (*Token, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	if s.token.ValidAt(s.config.now()) {
+		return s.token, nil
+	}
+
+	return s.refresh(ctx)
+}
+
+func (s *RefreshingTokenSource) Reject(ctx context.Context, token *Token) (*Token, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	// Another caller has already replaced the rejected token
+	if token != nil && s.token != nil && s.token.AccessToken != token.AccessToken && s.token.ValidAt(s.config.now()) {
+		return s.token, nil
+	}
+
+	return s.refresh(ctx)
+}
+
+// refresh replaces the token with a new one; the source must be locked by the caller
+func (s *RefreshingTokenSource) refresh(ctx context.Context) (*Token, error) {
+	if s.token == nil || s.token.RefreshToken == "" {
+		return nil, ErrNoRefreshToken
+	}
+
+	token, err := s.config.Refresh(ctx, s.token.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 6749 leaves the scope out when it is the one originally granted
+	if len(token.Scopes) == 0 {
+		token.Scopes = s.token.Scopes
+	}
+	s.token = token
+
+	if s.persist != nil {
+		s.persist(token)
+	}
+
+	return token, nil
+}
+
+// lock takes the source, waiting no longer than ctx allows. A free source is taken even when ctx is already done, so a
+// stored token that is still valid can be handed out without a refresh.
+func (s *RefreshingTokenSource) lock(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *RefreshingTokenSource) unlock() {
+	<-s.sem
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRefreshingTokenSourceSharesRefresh(t *testing.T) {
+	s := newAuthServer(t)
+	c := newTestConfig(s)
+
+	var persisted []*Token
+	var mu sync.Mutex
+	source := c.TokenSource(&Token{
+		AccessToken:  "expired",
+		RefreshToken: "refresh-1",
+		Expiry:       c.now().Add(-1 * time.Minute),
+	}, func(token *Token) {
+		mu.Lock()
+		defer mu.Unlock()
+		persisted = append(persisted, token)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			token, err := source.Token(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if token.AccessToken != "access-1" {
+				t.Errorf("unexpected token: %s", token.AccessToken)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s.issued != 1 {
+		t.Errorf("concurrent callers did not share a refresh; refreshes: %d", s.issued)
+	}
+	if len(persisted) != 1 || persisted[0].AccessToken != "access-1" {
+		t.Errorf("new token was not persisted once; got: %v", persisted)
+	}
+}
+
+func TestRefreshingTokenSourceReject(t *testing.T) {
+	s := newAuthServer(t)
+	c := newTestConfig(s)
+
+	old := &Token{AccessToken: "revoked", RefreshToken: "refresh-1"}
+	source := c.TokenSource(old, nil)
+
+	replacement, err := source.Reject(context.Background(), old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replacement.AccessToken != "access-1" {
+		t.Errorf("unexpected replacement: %s", replacement.AccessToken)
+	}
+
+	// A late rejection of the same token gets the replacement instead of another refresh
+	if replacement, err = source.Reject(context.Background(), old); err != nil || replacement.AccessToken != "access-1" {
+		t.Errorf("unexpected replacement: %v, %v", replacement, err)
+	}
+	if s.issued != 1 {
+		t.Errorf("unexpected refresh count; expected: 1, got: %d", s.issued)
+	}
+
+	// Without a refresh token an expired token cannot be replaced
+	stuck := c.TokenSource(&Token{AccessToken: "expired", Expiry: c.now().Add(-1 * time.Minute)}, nil)
+	if _, err = stuck.Token(context.Background()); !errors.Is(err, ErrNoRefreshToken) {
+		t.Errorf("unexpected error; expected: %v, got: %v", ErrNoRefreshToken, err)
+	}
+}
+
+func TestRefreshingTokenSourceDoneContext(t *testing.T) {
+	c := NewConfig("CLIENT_ID", "http://localhost/callback")
+	source := c.TokenSource(&Token{AccessToken: "valid"}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A valid token needs no refresh, so a done context does not stand in the way
+	for i := 0; i < 50; i++ {
+		if token, err := source.Token(ctx); err != nil || token.AccessToken != "valid" {
+			t.Fatalf("unexpected result; got: %v, %v", token, err)
+		}
+	}
+
+	// Waiting for a busy source does honour it
+	source.sem <- struct{}{}
+	defer source.unlock()
+	if _, err := source.Token(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error; expected: %v, got: %v", context.Canceled, err)
+	}
+}
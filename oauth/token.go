@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2023. Veteran Software
+ *
+ * Picarto API Wrapper - A custom wrapper for the Picarto REST API developed for a proprietary project.
+ *
+ * This program is free software: you can redistribute it and/or modify it under the terms of the GNU General Public
+ * License as published by the Free Software Foundation, either version 3 of the License, or (at your option) any later
+ * version.
+ *
+ * This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied
+ * warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License along with this program.
+ * If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package oauth
+
+import "time"
+
+// expiryDelta is how long before its expiry a token stops being valid, so it is not sent just as it expires
+const expiryDelta = 10 * time.Second
+
+// Token
+//
+// An access token issued to the application for a user. It can be stored as JSON and loaded again later.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Expiry is when the access token expires; the zero value means it does not expire
+	Expiry time.Time `json:"expiry,omitempty"`
+	// Scopes are the scopes granted by the user, which may be fewer than were asked for
+	Scopes []Scope `json:"scopes,omitempty"`
+}
+
+// HasScopes reports whether every one of scopes was granted
+func (t *Token) HasScopes(scopes ...Scope) bool {
+	return len(t.MissingScopes(scopes...)) == 0
+}
+
+// MissingScopes returns those of scopes that were not granted, in order and each only once
+func (t *Token) MissingScopes(scopes ...Scope) []Scope {
+	var missing []Scope
+	for _, scope := range scopes {
+		if (t == nil || !containsScope(t.Scopes, scope)) && !containsScope(missing, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+func containsScope(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Valid reports whether the token has an access token that does not expire within the next few seconds
+func (t *Token) Valid() bool {
+	return t.ValidAt(time.Now())
+}
+
+// ValidAt is the same as Valid, but at now
+func (t *Token) ValidAt(now time.Time) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+
+	return t.Expiry.IsZero() || now.Add(expiryDelta).Before(t.Expiry)
+}